@@ -0,0 +1,183 @@
+package nwenc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// bitEncoderBufSize is the bytes which BitEncoder buffers before writing.
+const bitEncoderBufSize = 4096
+
+// BitEncoder encodes int64 offsets to a continuous bitstream of bitLen bits each.
+// The bits are written from the most significant one, so the order of the offsets
+// is kept in the order of the bytes.
+// BitEncoder buffers the output, so the caller must call Flush after the last Encode.
+type BitEncoder struct {
+	w      io.Writer
+	bitLen int
+	buf    []byte // the complete bytes which are not written yet
+	cur    byte   // the incomplete byte
+	n      int    // the number of bits in cur
+}
+
+// BitRangeError is returned when an offset is negative or does not fit in the bit length.
+type BitRangeError struct {
+	offset int64
+	bitLen int
+}
+
+func (e *BitRangeError) Error() string {
+	return fmt.Sprintf("offset out of range of %d bits: %v", e.bitLen, e.offset)
+}
+
+// NewBitEncoder returns a BitEncoder which writes to w.
+// The bitLen must be 1 <= bitLen <= 63.
+func NewBitEncoder(w io.Writer, bitLen int) (*BitEncoder, error) {
+	if bitLen < 1 || 63 < bitLen {
+		return nil, fmt.Errorf("invalid bit length: %d", bitLen)
+	}
+	return &BitEncoder{w: w, bitLen: bitLen, buf: make([]byte, 0, bitEncoderBufSize)}, nil
+}
+
+// Encode encodes offset to bitLen bits.
+// When offset does not fit in bitLen bits, it will return BitRangeError.
+func (e *BitEncoder) Encode(offset int64) error {
+	if offset < 0 || uint64(offset) >= 1<<uint(e.bitLen) {
+		return &BitRangeError{offset: offset, bitLen: e.bitLen}
+	}
+
+	for rest := e.bitLen; rest > 0; {
+		take := 8 - e.n
+		if take > rest {
+			take = rest
+		}
+		bits := byte(uint64(offset)>>uint(rest-take)) & (1<<uint(take) - 1)
+		e.cur = e.cur<<uint(take) | bits
+		e.n += take
+		rest -= take
+
+		if e.n == 8 {
+			e.buf = append(e.buf, e.cur)
+			e.cur, e.n = 0, 0
+		}
+	}
+
+	if len(e.buf) >= bitEncoderBufSize {
+		return e.write()
+	}
+	return nil
+}
+
+// EncodeString encodes s to bitLen bits.
+func (e *BitEncoder) EncodeString(oe OffsetEncoder, s string) error {
+	offset, err := oe.OffsetEncode(s)
+	if err != nil {
+		return err
+	}
+	if err := e.Encode(offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Flush pads the incomplete byte with zero bits and writes all the buffered bytes to w.
+// The next Encode starts at a byte boundary, so the decoder must call Align
+// at the same position when Flush is called between offsets.
+func (e *BitEncoder) Flush() error {
+	if e.n > 0 {
+		e.buf = append(e.buf, e.cur<<uint(8-e.n))
+		e.cur, e.n = 0, 0
+	}
+	return e.write()
+}
+
+// write writes the complete bytes to w.
+func (e *BitEncoder) write() error {
+	if len(e.buf) == 0 {
+		return nil
+	}
+	_, err := e.w.Write(e.buf)
+	e.buf = e.buf[:0]
+	return err
+}
+
+// BitDecoder decodes a continuous bitstream which BitEncoder writes to int64 offsets or strings.
+//
+// The zero bits padded by Flush are not decoded when they are fewer than bitLen.
+// When bitLen < 8, they may be decoded as zero offsets, so the caller should know
+// the number of offsets in that case.
+type BitDecoder struct {
+	r      io.ByteReader
+	bitLen int
+	cur    byte // the byte which is being read
+	n      int  // the number of unread bits in cur
+}
+
+// NewBitDecoder returns a BitDecoder which reads from r.
+// The bitLen must be 1 <= bitLen <= 63.
+func NewBitDecoder(r io.Reader, bitLen int) (*BitDecoder, error) {
+	if bitLen < 1 || 63 < bitLen {
+		return nil, fmt.Errorf("invalid bit length: %d", bitLen)
+	}
+
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &BitDecoder{r: br, bitLen: bitLen}, nil
+}
+
+// Decode reads bitLen bits and decodes to the offset.
+// It returns io.EOF when only the padding is left, and io.ErrUnexpectedEOF when
+// the stream ends in the middle of an offset.
+func (d *BitDecoder) Decode() (offset int64, err error) {
+	var u uint64
+	padding := d.n // the unread bits of the last byte may be the padding
+	for rest := d.bitLen; rest > 0; {
+		if d.n == 0 {
+			var b byte
+			b, err = d.r.ReadByte()
+			if err == io.EOF {
+				if padding == d.bitLen-rest && u == 0 {
+					return 0, io.EOF
+				}
+				return 0, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return 0, err
+			}
+			d.cur, d.n = b, 8
+			padding = -1
+		}
+
+		take := d.n
+		if take > rest {
+			take = rest
+		}
+		bits := uint64(d.cur>>uint(d.n-take)) & (1<<uint(take) - 1)
+		u = u<<uint(take) | bits
+		d.n -= take
+		rest -= take
+	}
+
+	return int64(u), nil
+}
+
+// DecodeString reads bitLen bits and decodes to s.
+func (d *BitDecoder) DecodeString(od OffsetDecoder) (s string, err error) {
+	offset, err := d.Decode()
+	if err != nil {
+		return
+	}
+	s, err = od.OffsetDecode(offset)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// Align skips the unread bits of the current byte, which are the padding written by Flush.
+func (d *BitDecoder) Align() {
+	d.n = 0
+}
@@ -0,0 +1,296 @@
+package nwenc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewBitEncoder(t *testing.T) {
+	tests := []struct {
+		in  int
+		out error
+	}{
+		{0, fmt.Errorf("invalid bit length: %d", 0)},
+		{1, nil},
+		{63, nil},
+		{64, fmt.Errorf("invalid bit length: %d", 64)},
+	}
+
+	for idx, test := range tests {
+		_, err := NewBitEncoder(new(bytes.Buffer), test.in)
+		if !reflect.DeepEqual(test.out, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, err)
+		}
+		_, err = NewBitDecoder(new(bytes.Buffer), test.in)
+		if !reflect.DeepEqual(test.out, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, err)
+		}
+	}
+}
+
+func TestBitEncoder_Encode(t *testing.T) {
+	type inType struct {
+		bitLen  int
+		offsets []int64
+	}
+	type outType struct {
+		buf []byte
+		err error
+	}
+	tests := []struct {
+		in  inType
+		out outType
+	}{
+		{
+			inType{3, []int64{}},
+			outType{nil, nil},
+		},
+		{
+			inType{3, []int64{1, 2, 3, 4}},
+			outType{[]byte{0x29, 0xC0}, nil},
+		},
+		{
+			inType{8, []int64{0xAB, 0xCD}},
+			outType{[]byte{0xAB, 0xCD}, nil},
+		},
+		{
+			inType{12, []int64{0xABC, 0xDEF}},
+			outType{[]byte{0xAB, 0xCD, 0xEF}, nil},
+		},
+		{
+			inType{21, []int64{0x1FFFFF}},
+			outType{[]byte{0xFF, 0xFF, 0xF8}, nil},
+		},
+		{
+			inType{3, []int64{8}},
+			outType{nil, &BitRangeError{offset: 8, bitLen: 3}},
+		},
+		{
+			inType{63, []int64{-1}},
+			outType{nil, &BitRangeError{offset: -1, bitLen: 63}},
+		},
+	}
+
+	for idx, test := range tests {
+		buf := new(bytes.Buffer)
+		enc, err := NewBitEncoder(buf, test.in.bitLen)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v", idx, err)
+		}
+
+		for _, offset := range test.in.offsets {
+			if err = enc.Encode(offset); err != nil {
+				break
+			}
+		}
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if err := enc.Flush(); err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+		}
+		if !reflect.DeepEqual(test.out.buf, buf.Bytes()) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.buf, buf.Bytes())
+		}
+	}
+}
+
+func TestBitDecoder_Decode(t *testing.T) {
+	type inType struct {
+		bitLen int
+		buf    []byte
+	}
+	type outType struct {
+		offsets []int64
+		err     error
+	}
+	tests := []struct {
+		in  inType
+		out outType
+	}{
+		{
+			inType{3, []byte{}},
+			outType{[]int64{}, nil},
+		},
+		{
+			inType{5, []byte{0x08, 0x86, 0x40}},
+			outType{[]int64{1, 2, 3, 4}, nil},
+		},
+		{
+			// the padding of 4 bits is decoded as 0 because it is not fewer than 3 bits
+			inType{3, []byte{0x29, 0xC0}},
+			outType{[]int64{1, 2, 3, 4, 0}, nil},
+		},
+		{
+			inType{12, []byte{0xAB, 0xCD, 0xEF}},
+			outType{[]int64{0xABC, 0xDEF}, nil},
+		},
+		{
+			inType{21, []byte{0xFF, 0xFF, 0xF8}},
+			outType{[]int64{0x1FFFFF}, nil},
+		},
+		{
+			inType{12, []byte{0xAB, 0xCD, 0xEF, 0x01}},
+			outType{[]int64{0xABC, 0xDEF}, io.ErrUnexpectedEOF},
+		},
+		{
+			inType{21, []byte{0xFF, 0xFF, 0xF8, 0x00}},
+			outType{[]int64{0x1FFFFF}, io.ErrUnexpectedEOF},
+		},
+		{
+			// non-zero padding
+			inType{5, []byte{0x08, 0x86, 0x41}},
+			outType{[]int64{1, 2, 3, 4}, io.ErrUnexpectedEOF},
+		},
+	}
+
+	for idx, test := range tests {
+		dec, err := NewBitDecoder(bytes.NewReader(test.in.buf), test.in.bitLen)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v", idx, err)
+		}
+
+		offsets := []int64{}
+		for {
+			var offset int64
+			offset, err = dec.Decode()
+			if err != nil {
+				break
+			}
+			offsets = append(offsets, offset)
+		}
+		if err == io.EOF {
+			err = nil
+		}
+
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.offsets, offsets) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.offsets, offsets)
+		}
+	}
+}
+
+func TestBitCodec_RoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for bitLen := 8; bitLen <= 63; bitLen++ {
+		for _, n := range []int{0, 1, 7, 1000} {
+			offsets := make([]int64, n)
+			for i := range offsets {
+				offsets[i] = rnd.Int63() >> uint(63-bitLen)
+			}
+
+			buf := new(bytes.Buffer)
+			enc, _ := NewBitEncoder(buf, bitLen)
+			for _, offset := range offsets {
+				if err := enc.Encode(offset); err != nil {
+					t.Fatalf("[%d %d] unexpected error: %v", bitLen, n, err)
+				}
+			}
+			if err := enc.Flush(); err != nil {
+				t.Fatalf("[%d %d] unexpected error: %v", bitLen, n, err)
+			}
+			if expected := (n*bitLen + 7) / 8; buf.Len() != expected {
+				t.Errorf("[%d %d] expected %d bytes, but got %d", bitLen, n, expected, buf.Len())
+			}
+
+			dec, _ := NewBitDecoder(buf, bitLen)
+			for i, offset := range offsets {
+				got, err := dec.Decode()
+				if err != nil || got != offset {
+					t.Fatalf("[%d %d %d] expected (%d, %v), but got (%d, %v)", bitLen, n, i, offset, nil, got, err)
+				}
+			}
+			if _, err := dec.Decode(); err != io.EOF {
+				t.Errorf("[%d %d] expected %v, but got %v", bitLen, n, io.EOF, err)
+			}
+		}
+	}
+}
+
+func TestBitCodec_FlushAndAlign(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc, _ := NewBitEncoder(buf, 5)
+	enc.Encode(1)
+	enc.Flush()
+	enc.Encode(2)
+	enc.Encode(3)
+	enc.Flush()
+
+	if expected := []byte{0x08, 0x10, 0xC0}; !reflect.DeepEqual(expected, buf.Bytes()) {
+		t.Errorf("expected %v, but got %v", expected, buf.Bytes())
+	}
+
+	dec, _ := NewBitDecoder(buf, 5)
+	out := []int64{}
+	offset, _ := dec.Decode()
+	out = append(out, offset)
+	dec.Align()
+	offset, _ = dec.Decode()
+	out = append(out, offset)
+	offset, _ = dec.Decode()
+	out = append(out, offset)
+
+	if expected := []int64{1, 2, 3}; !reflect.DeepEqual(expected, out) {
+		t.Errorf("expected %v, but got %v", expected, out)
+	}
+	// the last padding is not fewer than 5 bits
+	dec.Align()
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected %v, but got %v", io.EOF, err)
+	}
+}
+
+func TestBitCodec_String(t *testing.T) {
+	// prepare OffsetMapper
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	om, err := NewAllReadOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	words := []string{"a", "bcd", "ijkl", "abcd"}
+	buf := new(bytes.Buffer)
+	enc, _ := NewBitEncoder(buf, 7)
+	for _, s := range words {
+		if err := enc.EncodeString(om, s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := enc.EncodeString(om, "z"); !reflect.DeepEqual(&OffsetEncodeError{s: "z"}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetEncodeError{s: "z"}, err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() != 4 {
+		t.Errorf("expected %d bytes, but got %d", 4, buf.Len())
+	}
+
+	dec, _ := NewBitDecoder(buf, 7)
+	for _, s := range words {
+		got, err := dec.DecodeString(om)
+		if err != nil || got != s {
+			t.Errorf("expected (%#v, %v), but got (%#v, %v)", s, nil, got, err)
+		}
+	}
+	if _, err := dec.DecodeString(om); err != io.EOF {
+		t.Errorf("expected %v, but got %v", io.EOF, err)
+	}
+}
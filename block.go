@@ -0,0 +1,312 @@
+package nwenc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The framed stream is a sequence of blocks. Each block consists of the header,
+// the encoded offsets and the checksum. All integers are big endian.
+//
+//	sync      [4]byte  "NWBK"
+//	seq       uint32   the index of the block
+//	count     uint32   the number of offsets
+//	length    uint32   the bytes of the payload
+//	payload   [length]byte
+//	crc       uint32   CRC-32C of seq, count, length and payload
+const (
+	blockSync       = "NWBK"
+	blockHeaderSize = 4 + 4 + 4 + 4
+	maxBlockLen     = 1 << 24
+)
+
+// BlockError is returned when a block of the framed stream is corrupt.
+type BlockError struct {
+	Block int64 // the index of the block
+	Pos   int64 // the byte position of the block in the stream
+	Err   error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("corrupt block %d at byte %d: %v", e.Block, e.Pos, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+// BlockWriter groups the offsets into blocks, each of which has a CRC-32C, and writes them.
+// The caller must call Flush after the last Encode.
+type BlockWriter struct {
+	w        io.Writer
+	enc      ByteEncoder
+	blockLen int
+	payload  bytes.Buffer
+	n        int // the number of offsets in payload
+	seq      uint32
+}
+
+// NewBlockWriter returns a BlockWriter which encodes offsets by enc and writes a block
+// of every blockLen offsets to w. The blockLen must be 1 <= blockLen.
+func NewBlockWriter(w io.Writer, enc ByteEncoder, blockLen int) (*BlockWriter, error) {
+	if blockLen < 1 {
+		return nil, fmt.Errorf("invalid block length: %d", blockLen)
+	}
+	return &BlockWriter{w: w, enc: enc, blockLen: blockLen}, nil
+}
+
+// Encode encodes offset. It writes the block when the block is full.
+func (bw *BlockWriter) Encode(offset int64) error {
+	if err := bw.enc.Encode(&bw.payload, offset); err != nil {
+		return err
+	}
+	bw.n++
+	if bw.n >= bw.blockLen || bw.payload.Len() >= maxBlockLen-maxVarintLen {
+		return bw.writeBlock()
+	}
+	return nil
+}
+
+// EncodeString encodes s. It writes the block when the block is full.
+func (bw *BlockWriter) EncodeString(oe OffsetEncoder, s string) error {
+	offset, err := oe.OffsetEncode(s)
+	if err != nil {
+		return err
+	}
+	if err := bw.Encode(offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Flush writes the incomplete block.
+func (bw *BlockWriter) Flush() error {
+	if bw.n == 0 {
+		return nil
+	}
+	return bw.writeBlock()
+}
+
+// writeBlock writes the offsets in payload as a block.
+func (bw *BlockWriter) writeBlock() error {
+	b := make([]byte, blockHeaderSize, blockHeaderSize+bw.payload.Len()+4)
+	copy(b, blockSync)
+	binary.BigEndian.PutUint32(b[4:], bw.seq)
+	binary.BigEndian.PutUint32(b[8:], uint32(bw.n))
+	binary.BigEndian.PutUint32(b[12:], uint32(bw.payload.Len()))
+	b = append(b, bw.payload.Bytes()...)
+	b = b[:len(b)+4]
+	binary.BigEndian.PutUint32(b[len(b)-4:], crc32.Checksum(b[4:len(b)-4], crc32cTable))
+
+	bw.payload.Reset()
+	bw.n = 0
+	bw.seq++
+
+	if _, err := bw.w.Write(b); err != nil {
+		return err
+	}
+	return nil
+}
+
+// BlockReader reads the framed stream which BlockWriter writes and verifies each block.
+// When a block is corrupt, Decode returns BlockError. The next Decode skips to the next
+// block and continues from it.
+type BlockReader struct {
+	r      *bufio.Reader
+	back   []byte // the bytes pushed back by corrupt, which are read before r
+	peeked []byte // the buffer of peek across back and r
+	dec    ByteDecoder
+	pos    int64 // the byte position of r in the stream
+	block  int64 // the index of the next block
+	resync bool  // whether the next block must be searched
+
+	raw     []byte
+	offsets []int64
+	i       int
+}
+
+// NewBlockReader returns a BlockReader which reads r and decodes offsets by dec.
+// The dec must be the counterpart of the ByteEncoder of the BlockWriter.
+func NewBlockReader(r io.Reader, dec ByteDecoder) *BlockReader {
+	return &BlockReader{r: bufio.NewReader(r), dec: dec}
+}
+
+// Decode decodes the next offset. It returns io.EOF at the end of the stream.
+// When a block is corrupt, it returns BlockError and the offsets of the block are skipped.
+func (br *BlockReader) Decode() (offset int64, err error) {
+	for br.i >= len(br.offsets) {
+		if err = br.readBlock(); err != nil {
+			return
+		}
+	}
+
+	offset = br.offsets[br.i]
+	br.i++
+	return
+}
+
+// DecodeString decodes the next string.
+func (br *BlockReader) DecodeString(od OffsetDecoder) (s string, err error) {
+	offset, err := br.Decode()
+	if err != nil {
+		return
+	}
+	s, err = od.OffsetDecode(offset)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// readBlock reads and verifies the next block.
+func (br *BlockReader) readBlock() error {
+	br.offsets, br.i = br.offsets[:0], 0
+
+	if br.resync {
+		if err := br.skipToSync(); err != nil {
+			return err
+		}
+		br.resync = false
+	}
+
+	pos := br.pos
+	if cap(br.raw) < blockHeaderSize {
+		br.raw = make([]byte, blockHeaderSize)
+	}
+	br.raw = br.raw[:blockHeaderSize]
+	n, err := br.readFull(br.raw)
+	br.pos += int64(n)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return br.corrupt(pos, err)
+	}
+	if string(br.raw[:4]) != blockSync {
+		return br.corrupt(pos, errors.New("bad sync marker"))
+	}
+
+	seq := int64(binary.BigEndian.Uint32(br.raw[4:]))
+	count := int(binary.BigEndian.Uint32(br.raw[8:]))
+	length := int(binary.BigEndian.Uint32(br.raw[12:]))
+	if length > maxBlockLen || count > length {
+		return br.corrupt(pos, fmt.Errorf("bad length: %d offsets in %d bytes", count, length))
+	}
+
+	size := blockHeaderSize + length + 4
+	if cap(br.raw) < size {
+		raw := make([]byte, size)
+		copy(raw, br.raw)
+		br.raw = raw
+	}
+	br.raw = br.raw[:size]
+	n, err = br.readFull(br.raw[blockHeaderSize:])
+	br.pos += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		br.raw = br.raw[:blockHeaderSize+n]
+		return br.corrupt(pos, err)
+	}
+	if binary.BigEndian.Uint32(br.raw[size-4:]) != crc32.Checksum(br.raw[4:size-4], crc32cTable) {
+		return br.corrupt(pos, errors.New("checksum mismatch"))
+	}
+
+	payload := bytes.NewReader(br.raw[blockHeaderSize : size-4])
+	for i := 0; i < count; i++ {
+		offset, err := br.dec.Decode(payload)
+		if err != nil {
+			br.offsets = br.offsets[:0]
+			return br.corrupt(pos, fmt.Errorf("bad payload: %v", err))
+		}
+		br.offsets = append(br.offsets, offset)
+	}
+	if payload.Len() > 0 {
+		br.offsets = br.offsets[:0]
+		return br.corrupt(pos, fmt.Errorf("bad payload: %d bytes left", payload.Len()))
+	}
+
+	br.block = seq + 1
+	return nil
+}
+
+// corrupt returns BlockError of the block at pos. The bytes of the block after its first byte
+// are read again to search the next block, because the length of the block may be broken.
+func (br *BlockReader) corrupt(pos int64, err error) error {
+	if len(br.raw) > 1 {
+		// the bytes of the block may have been read from back
+		rest := make([]byte, 0, len(br.raw)-1+len(br.back))
+		rest = append(rest, br.raw[1:]...)
+		br.back = append(rest, br.back...)
+	}
+	br.pos = pos + 1
+	br.resync = true
+
+	e := &BlockError{Block: br.block, Pos: pos, Err: err}
+	br.block++
+	return e
+}
+
+// skipToSync skips bytes until the sync marker of the next block.
+func (br *BlockReader) skipToSync() error {
+	for {
+		b, err := br.peek(len(blockSync))
+		if string(b) == blockSync {
+			return nil
+		}
+		if err != nil {
+			// the last bytes are not a block
+			br.pos += int64(len(b))
+			if err == io.EOF {
+				return io.EOF
+			}
+			return err
+		}
+		if _, err := br.discard(1); err != nil {
+			return err
+		}
+		br.pos++
+	}
+}
+
+// readFull reads exactly len(p) bytes from back and r in the same way as io.ReadFull.
+func (br *BlockReader) readFull(p []byte) (n int, err error) {
+	n = copy(p, br.back)
+	br.back = br.back[n:]
+	m, err := io.ReadFull(br.r, p[n:])
+	if err == io.EOF && n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n + m, err
+}
+
+// peek returns the next n bytes of back and r without reading them in the same way as
+// bufio.Reader.Peek.
+func (br *BlockReader) peek(n int) ([]byte, error) {
+	if len(br.back) >= n {
+		return br.back[:n], nil
+	}
+	if len(br.back) == 0 {
+		return br.r.Peek(n)
+	}
+	b, err := br.r.Peek(n - len(br.back))
+	br.peeked = append(append(br.peeked[:0], br.back...), b...)
+	return br.peeked, err
+}
+
+// discard skips the next n bytes of back and r in the same way as bufio.Reader.Discard.
+func (br *BlockReader) discard(n int) (discarded int, err error) {
+	discarded = n
+	if discarded > len(br.back) {
+		discarded = len(br.back)
+	}
+	br.back = br.back[discarded:]
+	m, err := br.r.Discard(n - discarded)
+	return discarded + m, err
+}
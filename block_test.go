@@ -0,0 +1,272 @@
+package nwenc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// blockTestOffsets are the offsets written in 4 blocks of 3, 3, 3 and 1 offsets.
+var blockTestOffsets = []int64{0, 1, 2, 300, 400, 500, 60000, 7, 8, 9}
+
+// writeTestBlocks writes blockTestOffsets by enc and returns the stream and
+// the byte positions of the blocks.
+func writeTestBlocks(t *testing.T, enc ByteEncoder) ([]byte, []int) {
+	buf := new(bytes.Buffer)
+	bw, err := NewBlockWriter(buf, enc, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var pos []int
+	for i, offset := range blockTestOffsets {
+		if i%3 == 0 {
+			pos = append(pos, buf.Len())
+		}
+		if err := bw.Encode(offset); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buf.Bytes(), pos
+}
+
+// readTestBlocks reads all offsets of b and the errors.
+func readTestBlocks(b []byte, dec ByteDecoder) (offsets []int64, errs []error) {
+	br := NewBlockReader(bytes.NewReader(b), dec)
+	for {
+		offset, err := br.Decode()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		offsets = append(offsets, offset)
+	}
+}
+
+func TestBlockWriter_BlockReader(t *testing.T) {
+	fixedEnc, _ := NewEncoder(3)
+	fixedDec, _ := NewDecoder(3)
+	tests := []struct {
+		enc ByteEncoder
+		dec ByteDecoder
+	}{
+		{fixedEnc, fixedDec},
+		{NewVarintEncoder(), NewVarintDecoder()},
+	}
+
+	for idx, test := range tests {
+		b, pos := writeTestBlocks(t, test.enc)
+		if len(pos) != 4 {
+			t.Errorf("[%d] expected %d blocks, but got %d", idx, 4, len(pos))
+		}
+
+		offsets, errs := readTestBlocks(b, test.dec)
+		if !reflect.DeepEqual(blockTestOffsets, offsets) {
+			t.Errorf("[%d] expected %v, but got %v", idx, blockTestOffsets, offsets)
+		}
+		if len(errs) > 0 {
+			t.Errorf("[%d] unexpected errors: %v", idx, errs)
+		}
+	}
+}
+
+func TestBlockReader_Corrupt(t *testing.T) {
+	enc, _ := NewEncoder(3)
+	dec, _ := NewDecoder(3)
+	b, pos := writeTestBlocks(t, enc)
+
+	// corrupt returns a copy of b whose bytes at idx are flipped.
+	corrupt := func(idx ...int) []byte {
+		c := append([]byte{}, b...)
+		for _, i := range idx {
+			c[i] ^= 0x01
+		}
+		return c
+	}
+
+	type errType struct {
+		block int64
+		pos   int
+		msg   string
+	}
+	tests := []struct {
+		in      []byte
+		offsets []int64
+		errs    []errType
+	}{
+		{
+			// payload
+			corrupt(pos[1] + blockHeaderSize + 4),
+			[]int64{0, 1, 2, 60000, 7, 8, 9},
+			[]errType{{1, pos[1], "checksum mismatch"}},
+		},
+		{
+			// sync marker
+			corrupt(pos[1]),
+			[]int64{0, 1, 2, 60000, 7, 8, 9},
+			[]errType{{1, pos[1], "bad sync marker"}},
+		},
+		{
+			// length is too long
+			corrupt(pos[1] + 12),
+			[]int64{0, 1, 2, 60000, 7, 8, 9},
+			[]errType{{1, pos[1], "bad length: 3 offsets in 16777225 bytes"}},
+		},
+		{
+			// length is shorter than the payload
+			corrupt(pos[1] + 15),
+			[]int64{0, 1, 2, 60000, 7, 8, 9},
+			[]errType{{1, pos[1], "checksum mismatch"}},
+		},
+		{
+			// crc
+			corrupt(pos[2] - 1),
+			[]int64{0, 1, 2, 60000, 7, 8, 9},
+			[]errType{{1, pos[1], "checksum mismatch"}},
+		},
+		{
+			// two blocks
+			corrupt(pos[0]+blockHeaderSize, pos[2]+blockHeaderSize),
+			[]int64{300, 400, 500, 9},
+			[]errType{{0, pos[0], "checksum mismatch"}, {2, pos[2], "checksum mismatch"}},
+		},
+		{
+			// truncated
+			b[:len(b)-1],
+			[]int64{0, 1, 2, 300, 400, 500, 60000, 7, 8},
+			[]errType{{3, pos[3], "unexpected EOF"}},
+		},
+		{
+			// garbage before the first block
+			append([]byte("garbage"), b...),
+			blockTestOffsets,
+			[]errType{{0, 0, "bad sync marker"}},
+		},
+	}
+
+	for idx, test := range tests {
+		offsets, errs := readTestBlocks(test.in, dec)
+		if !reflect.DeepEqual(test.offsets, offsets) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.offsets, offsets)
+		}
+		if len(test.errs) != len(errs) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.errs, errs)
+			continue
+		}
+		for i, err := range errs {
+			var e *BlockError
+			if !errors.As(err, &e) {
+				t.Errorf("[%d] expected BlockError, but got %v", idx, err)
+				continue
+			}
+			got := errType{e.Block, int(e.Pos), e.Err.Error()}
+			if test.errs[i] != got {
+				t.Errorf("[%d] expected %v, but got %v", idx, test.errs[i], got)
+			}
+		}
+	}
+}
+
+func TestBlockReader_ManyCorrupt(t *testing.T) {
+	enc, _ := NewEncoder(3)
+	dec, _ := NewDecoder(3)
+	buf := new(bytes.Buffer)
+	bw, _ := NewBlockWriter(buf, enc, 1)
+
+	const n = 1000
+	for i := 0; i < n; i++ {
+		pos := buf.Len()
+		if err := bw.Encode(int64(i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// the payload of all blocks but the last is broken
+		if i < n-1 {
+			buf.Bytes()[pos+blockHeaderSize] ^= 0x01
+		}
+	}
+
+	br := NewBlockReader(bytes.NewReader(buf.Bytes()), dec)
+	r := br.r
+	var offsets []int64
+	errs := 0
+	for {
+		offset, err := br.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs++
+			continue
+		}
+		offsets = append(offsets, offset)
+	}
+
+	if errs != n-1 {
+		t.Errorf("expected %d errors, but got %d", n-1, errs)
+	}
+	if expected := []int64{n - 1}; !reflect.DeepEqual(expected, offsets) {
+		t.Errorf("expected %v, but got %v", expected, offsets)
+	}
+	// the corrupt blocks must not stack the readers
+	if br.r != r || len(br.back) != 0 {
+		t.Errorf("expected the same reader without pushed back bytes, but got %p and %d bytes", br.r, len(br.back))
+	}
+}
+
+func TestBlockReader_DecodeString(t *testing.T) {
+	om, err := NewAllReadOffsetMapper(strings.NewReader("a\nbcd\nefg\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	enc, _ := NewEncoder(1)
+	dec, _ := NewDecoder(1)
+
+	buf := new(bytes.Buffer)
+	bw, err := NewBlockWriter(buf, enc, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	words := []string{"efg", "a", "bcd"}
+	for _, word := range words {
+		if err := bw.EncodeString(om, word); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	br := NewBlockReader(buf, dec)
+	var out []string
+	for {
+		s, err := br.DecodeString(om)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out = append(out, s)
+	}
+	if !reflect.DeepEqual(words, out) {
+		t.Errorf("expected %v, but got %v", words, out)
+	}
+}
+
+func TestNewBlockWriter_Invalid(t *testing.T) {
+	enc, _ := NewEncoder(1)
+	for _, blockLen := range []int{0, -1} {
+		if _, err := NewBlockWriter(new(bytes.Buffer), enc, blockLen); err == nil {
+			t.Errorf("expected error, but got nil: %d", blockLen)
+		}
+	}
+}
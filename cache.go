@@ -0,0 +1,198 @@
+package nwenc
+
+import (
+	"container/heap"
+	"sync/atomic"
+)
+
+// EvictionPolicy is the policy which chooses the cache entry to be evicted
+// when the cache of CachedSeekOffsetMapper is full.
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the least recently used entry.
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU evicts the least frequently used entry.
+	EvictLFU
+	// EvictClock evicts the entry by the CLOCK (second chance) algorithm.
+	// It costs less than EvictLRU and approximates it.
+	EvictClock
+)
+
+// CacheConfig is the configuration of the cache of CachedSeekOffsetMapper.
+// The zero value means the cache grows without limit.
+type CacheConfig struct {
+	// MaxEntries is the max number of cached lines. 0 means no limit.
+	MaxEntries int
+
+	// MaxBytes is the approximate max bytes of cached lines including their
+	// bookkeeping overhead. 0 means no limit.
+	MaxBytes int64
+
+	// Policy chooses the entry to be evicted when the cache is full.
+	Policy EvictionPolicy
+}
+
+// CacheStats is the statistics of the cache of CachedSeekOffsetMapper.
+type CacheStats struct {
+	Entries   int    // the number of cached lines
+	Bytes     int64  // the approximate bytes of cached lines
+	Hits      uint64 // the number of calls which are answered by the cache
+	Misses    uint64 // the number of calls which read io.ReaderAt
+	Evictions uint64 // the number of evicted lines
+}
+
+// cacheEntryOverhead is the approximate bytes of a cacheEntry, its map slot and its tree node
+// except the string data.
+const cacheEntryOverhead = 128
+
+// cacheEntry is a cached line.
+type cacheEntry struct {
+	// key is the access record used by the policy. It is accessed atomically.
+	// It is at the top of the struct for 64-bit alignment.
+	key uint64
+	// ref is the reference bit of EvictClock. It is accessed atomically.
+	ref uint32
+
+	s      string
+	offset int64
+	inTree bool // whether the entry is also in the offsetNode tree
+
+	stamp uint64 // the key by which heapPolicy orders the entry
+	seq   uint64 // the order of push which breaks ties of the stamp
+}
+
+// size returns the approximate bytes of e.
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.s)) + cacheEntryOverhead
+}
+
+// cachePolicy chooses the cache entry to be evicted.
+type cachePolicy interface {
+	// push adds the new entry e. The caller must hold the write lock.
+	push(e *cacheEntry)
+
+	// touch records an access to e. The caller needs to hold only the read lock,
+	// so it must be safe for concurrent use.
+	touch(e *cacheEntry)
+
+	// evict removes the entry to be evicted and returns it. The caller must hold the write lock.
+	evict() *cacheEntry
+}
+
+// newCachePolicy returns the cachePolicy of p.
+func newCachePolicy(p EvictionPolicy) cachePolicy {
+	switch p {
+	case EvictLFU:
+		return &heapPolicy{lfu: true}
+	case EvictClock:
+		return &clockPolicy{}
+	default:
+		return &heapPolicy{}
+	}
+}
+
+// heapPolicy is the cachePolicy for EvictLRU and EvictLFU. The key of an entry is the last
+// access time for LRU and the access count for LFU. Because touch cannot reorder the heap
+// without the write lock, the heap is ordered by the stamp taken on push and fixed lazily
+// on evict. The key never decreases, so the entry whose key equals its stamp at the top
+// has the least key.
+type heapPolicy struct {
+	clock uint64 // the logical time for LRU. It is accessed atomically.
+	lfu   bool
+	seq   uint64
+	h     entryHeap
+}
+
+func (p *heapPolicy) push(e *cacheEntry) {
+	if p.lfu {
+		e.key = 1
+	} else {
+		e.key = atomic.AddUint64(&p.clock, 1)
+	}
+	e.stamp = e.key
+	p.seq++
+	e.seq = p.seq
+	heap.Push(&p.h, e)
+}
+
+func (p *heapPolicy) touch(e *cacheEntry) {
+	if p.lfu {
+		atomic.AddUint64(&e.key, 1)
+	} else {
+		atomic.StoreUint64(&e.key, atomic.AddUint64(&p.clock, 1))
+	}
+}
+
+func (p *heapPolicy) evict() *cacheEntry {
+	for {
+		e := p.h[0]
+		key := atomic.LoadUint64(&e.key)
+		if key == e.stamp {
+			heap.Pop(&p.h)
+			return e
+		}
+		e.stamp = key
+		heap.Fix(&p.h, 0)
+	}
+}
+
+// entryHeap is the min heap of cacheEntry ordered by the stamp and the seq.
+type entryHeap []*cacheEntry
+
+func (h entryHeap) Len() int { return len(h) }
+
+func (h entryHeap) Less(i, j int) bool {
+	if h[i].stamp != h[j].stamp {
+		return h[i].stamp < h[j].stamp
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h entryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *entryHeap) Push(x interface{}) { *h = append(*h, x.(*cacheEntry)) }
+
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// clockPolicy is the cachePolicy for EvictClock. It is implemented as the second chance
+// FIFO queue, which is equivalent to CLOCK: an entry referenced since it was queued is
+// queued again instead of being evicted.
+type clockPolicy struct {
+	queue []*cacheEntry
+	head  int
+}
+
+func (p *clockPolicy) push(e *cacheEntry) {
+	p.queue = append(p.queue, e)
+}
+
+func (p *clockPolicy) touch(e *cacheEntry) {
+	atomic.StoreUint32(&e.ref, 1)
+}
+
+func (p *clockPolicy) evict() *cacheEntry {
+	for {
+		e := p.queue[p.head]
+		p.queue[p.head] = nil
+		p.head++
+
+		// reclaim the consumed head of the queue
+		if p.head*2 >= len(p.queue) {
+			n := copy(p.queue, p.queue[p.head:])
+			p.queue = p.queue[:n]
+			p.head = 0
+		}
+
+		if atomic.SwapUint32(&e.ref, 0) == 1 {
+			p.queue = append(p.queue, e)
+			continue
+		}
+		return e
+	}
+}
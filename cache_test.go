@@ -0,0 +1,89 @@
+package nwenc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCachePolicy(t *testing.T) {
+	type inType struct {
+		policy  EvictionPolicy
+		touches []string
+	}
+	tests := []struct {
+		in  inType
+		out []string
+	}{
+		{
+			inType{EvictLRU, nil},
+			[]string{"a", "b", "c", "d"},
+		},
+		{
+			inType{EvictLRU, []string{"a", "c", "b", "a"}},
+			[]string{"d", "c", "b", "a"},
+		},
+		{
+			inType{EvictLFU, nil},
+			[]string{"a", "b", "c", "d"},
+		},
+		{
+			inType{EvictLFU, []string{"a", "a", "a", "b", "d", "d"}},
+			[]string{"c", "b", "d", "a"},
+		},
+		{
+			inType{EvictClock, nil},
+			[]string{"a", "b", "c", "d"},
+		},
+		{
+			inType{EvictClock, []string{"a", "c", "c"}},
+			[]string{"b", "d", "a", "c"},
+		},
+	}
+
+	for idx, test := range tests {
+		p := newCachePolicy(test.in.policy)
+		entries := map[string]*cacheEntry{}
+		for _, s := range []string{"a", "b", "c", "d"} {
+			entries[s] = &cacheEntry{s: s}
+			p.push(entries[s])
+		}
+		for _, s := range test.in.touches {
+			p.touch(entries[s])
+		}
+
+		out := []string{}
+		for range entries {
+			out = append(out, p.evict().s)
+		}
+
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, out)
+		}
+	}
+}
+
+func TestCachePolicy_Interleaved(t *testing.T) {
+	// evicted entries must never be returned twice even when push and evict interleave
+	for _, policy := range []EvictionPolicy{EvictLRU, EvictLFU, EvictClock} {
+		p := newCachePolicy(policy)
+		live := map[*cacheEntry]bool{}
+		for i := 0; i < 1000; i++ {
+			e := &cacheEntry{offset: int64(i)}
+			p.push(e)
+			live[e] = true
+			for e := range live {
+				if i%3 == 0 {
+					p.touch(e)
+				}
+				break
+			}
+			if len(live) > 10 {
+				e := p.evict()
+				if !live[e] {
+					t.Fatalf("[%d] evicted unknown entry %v", policy, e.offset)
+				}
+				delete(live, e)
+			}
+		}
+	}
+}
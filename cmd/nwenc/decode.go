@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/high-moctane/nwenc"
+)
+
+// runDecode runs the decode command.
+func runDecode(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("nwenc decode", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: nwenc decode -vocab file [-bytelen n] [-mapper name] [file ...]")
+		fs.PrintDefaults()
+	}
+	var vf vocabFlags
+	vf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	v, err := vf.open()
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc decode: %v\n", err)
+		return exitUsage
+	}
+	defer v.close()
+
+	dec, err := nwenc.NewDecoder(v.byteLen)
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc decode: %v\n", err)
+		return exitUsage
+	}
+
+	w := bufio.NewWriter(stdout)
+	invalid := 0
+	err = forEachInput(fs.Args(), stdin, func(in input) error {
+		sd := nwenc.NewStreamDecoder(in.r, dec)
+		for {
+			s, err := sd.DecodeString(v.om)
+			if err == io.EOF {
+				return nil
+			}
+			var derr *nwenc.DecodeError
+			var oerr *nwenc.OffsetDecodeError
+			if errors.As(err, &derr) && errors.As(err, &oerr) {
+				fmt.Fprintf(stderr, "%s: record %d (byte %d): %v\n", in.name, derr.Record, derr.Pos, oerr)
+				invalid++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %v", in.name, err)
+			}
+			fmt.Fprintln(w, s)
+		}
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc decode: %v\n", err)
+		return exitError
+	}
+
+	if invalid > 0 {
+		fmt.Fprintf(stderr, "nwenc decode: %d codes not in vocabulary\n", invalid)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/high-moctane/nwenc"
+)
+
+// The statuses of a dumped record.
+const (
+	statusOutOfRange = "out of range" // the offset is not in the vocabulary file
+	statusMidLine    = "mid-line"     // the offset is not at the beginning of a line
+	statusInvalid    = "invalid"      // the mapper cannot decode the offset
+)
+
+// dumpRecord is a record of the dump command.
+type dumpRecord struct {
+	Index  int64  `json:"index"`
+	Pos    int64  `json:"pos"`
+	Code   string `json:"code"`
+	Offset int64  `json:"offset"`
+	Word   string `json:"word"`
+	Status string `json:"status,omitempty"`
+}
+
+// runDump runs the dump command.
+func runDump(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("nwenc dump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: nwenc dump -vocab file [-bytelen n] [-mapper name] [-start i] [-n count] [-json] [-highlight] [file]")
+		fs.PrintDefaults()
+	}
+	var vf vocabFlags
+	vf.register(fs)
+	start := fs.Int64("start", 0, "the index of the first record to dump")
+	count := fs.Int64("n", -1, "the number of records to dump (default: all)")
+	asJSON := fs.Bool("json", false, "print the records in JSON, one object per line")
+	highlight := fs.Bool("highlight", false, "mark the offsets which are out of range, in the middle of a line or not decodable")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *start < 0 {
+		fmt.Fprintf(stderr, "nwenc dump: invalid start: %d\n", *start)
+		return exitUsage
+	}
+
+	v, err := vf.open()
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc dump: %v\n", err)
+		return exitUsage
+	}
+	defer v.close()
+
+	dec, err := nwenc.NewDecoder(v.byteLen)
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc dump: %v\n", err)
+		return exitUsage
+	}
+
+	w := bufio.NewWriter(stdout)
+	jw := json.NewEncoder(w)
+	err = forEachInput(fs.Args(), stdin, func(in input) error {
+		r := bufio.NewReader(in.r)
+		pos := *start * int64(v.byteLen)
+		if _, err := io.CopyN(ioutil.Discard, r, pos); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", in.name, err)
+		}
+
+		b := make([]byte, v.byteLen)
+		var offset [1]int64
+		for i := *start; *count < 0 || i < *start+*count; i++ {
+			if _, err := io.ReadFull(r, b); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("%s: record %d (byte %d): %v", in.name, i, pos, err)
+			}
+			if _, err := dec.DecodeInto(offset[:], b); err != nil {
+				return fmt.Errorf("%s: record %d (byte %d): %v", in.name, i, pos, err)
+			}
+
+			rec := dumpRecord{Index: i, Pos: pos, Code: hex.EncodeToString(b), Offset: offset[0]}
+			var status string
+			rec.Word, status, err = dumpWord(v, offset[0])
+			if err != nil {
+				return err
+			}
+			if *highlight {
+				rec.Status = status
+			}
+
+			if *asJSON {
+				err = jw.Encode(rec)
+			} else {
+				err = writeDumpRecord(w, rec)
+			}
+			if err != nil {
+				return err
+			}
+			pos += int64(v.byteLen)
+		}
+		return nil
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc dump: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// dumpWord returns the word of offset and its status, which is empty when the offset is valid.
+// The word of a mid-line offset is what the mapper decodes, if any.
+func dumpWord(v *vocab, offset int64) (word, status string, err error) {
+	if offset < 0 || offset >= v.size {
+		return "", statusOutOfRange, nil
+	}
+	if offset > 0 {
+		var b [1]byte
+		if _, err := v.r.ReadAt(b[:], offset-1); err != nil {
+			return "", "", err
+		}
+		if b[0] != '\n' {
+			status = statusMidLine
+		}
+	}
+
+	word, err = v.om.OffsetDecode(offset)
+	var derr *nwenc.OffsetDecodeError
+	if errors.As(err, &derr) {
+		if status == "" {
+			status = statusInvalid
+		}
+		return "", status, nil
+	}
+	return word, status, err
+}
+
+// writeDumpRecord writes rec in a tab-separated line.
+func writeDumpRecord(w io.Writer, rec dumpRecord) error {
+	var err error
+	if rec.Status == "" {
+		_, err = fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%q\n", rec.Index, rec.Pos, rec.Code, rec.Offset, rec.Word)
+	} else {
+		_, err = fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%q\t!%s\n", rec.Index, rec.Pos, rec.Code, rec.Offset, rec.Word, rec.Status)
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/high-moctane/nwenc"
+)
+
+// runEncode runs the encode command.
+func runEncode(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("nwenc encode", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: nwenc encode -vocab file [-bytelen n] [-mapper name] [file ...]")
+		fs.PrintDefaults()
+	}
+	var vf vocabFlags
+	vf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	v, err := vf.open()
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc encode: %v\n", err)
+		return exitUsage
+	}
+	defer v.close()
+
+	enc, err := nwenc.NewEncoder(v.byteLen)
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc encode: %v\n", err)
+		return exitUsage
+	}
+
+	w := bufio.NewWriter(stdout)
+	missing := 0
+	err = forEachInput(fs.Args(), stdin, func(in input) error {
+		sc := bufio.NewScanner(in.r)
+		for line := 1; sc.Scan(); line++ {
+			err := enc.EncodeString(w, v.om, sc.Text())
+			var eerr *nwenc.OffsetEncodeError
+			if errors.As(err, &eerr) {
+				fmt.Fprintf(stderr, "%s:%d: word not in vocabulary: %q\n", in.name, line, sc.Text())
+				missing++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", in.name, line, err)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return fmt.Errorf("%s: %v", in.name, err)
+		}
+		return nil
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc encode: %v\n", err)
+		return exitError
+	}
+
+	if missing > 0 {
+		fmt.Fprintf(stderr, "nwenc encode: %d words not in vocabulary\n", missing)
+		return exitError
+	}
+	return exitOK
+}
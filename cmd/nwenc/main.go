@@ -0,0 +1,105 @@
+// Command nwenc encodes/decodes words by the offsets in a vocabulary file.
+//
+// Usage:
+//
+//	nwenc encode -vocab words.txt [-bytelen n] [-mapper name] [file ...]
+//	nwenc decode -vocab words.txt [-bytelen n] [-mapper name] [file ...]
+//	nwenc dump -vocab words.txt [-bytelen n] [-mapper name] [-start i] [-n count] [-json] [-highlight] [file]
+//	nwenc verify [file ...]
+//
+// The encode reads words, one per line, from the files or the standard input and writes
+// their fixed-width codes to the standard output. The decode does the reverse.
+// The words or the codes which are not in the vocabulary are reported with their positions,
+// and the exit status is 1.
+//
+// The dump prints the codes of an encoded file, one record per line, with the index,
+// the byte position, the code in hex, the offset and the word. The -highlight marks the
+// offsets which are out of range, in the middle of a line or not decodable.
+//
+// The verify checks that the vocabulary files are sorted in byte order without duplicates,
+// empty lines, CRLF endings, too long lines and invalid UTF-8, and prints the minimum
+// byte length of the codes. The exit status is 1 on errors.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// The exit statuses.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `usage: nwenc <command> [flags] [file ...]
+
+commands:
+	encode  encode words to fixed-width codes
+	decode  decode fixed-width codes to words
+	dump    print the records of an encoded file
+	verify  check a vocabulary file
+
+Run "nwenc <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command of args and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	var cmd func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+	switch args[0] {
+	case "encode":
+		cmd = runEncode
+	case "decode":
+		cmd = runDecode
+	case "dump":
+		cmd = runDump
+	case "verify":
+		cmd = runVerify
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "nwenc: unknown command: %s\n", args[0])
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	return cmd(args[1:], stdin, stdout, stderr)
+}
+
+// input is a named input of a command.
+type input struct {
+	name string
+	r    io.Reader
+}
+
+// forEachInput calls fn with each file of names, or with stdin when names is empty.
+// It stops at the first error.
+func forEachInput(names []string, stdin io.Reader, fn func(in input) error) error {
+	if len(names) == 0 {
+		return fn(input{name: "<stdin>", r: stdin})
+	}
+
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = fn(input{name: name, r: f})
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
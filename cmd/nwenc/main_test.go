@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testVocab is the vocabulary file of the tests.
+var testVocab = filepath.Join("..", "..", "testdata", "words.txt")
+
+// runTest runs the command with stdin and returns the exit status, stdout and stderr.
+func runTest(args []string, stdin string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	status := run(args, strings.NewReader(stdin), stdout, stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{nil, exitUsage},
+		{[]string{"unknown"}, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"encode"}, exitUsage},
+		{[]string{"encode", "-vocab", "nonexistent"}, exitUsage},
+		{[]string{"encode", "-vocab", testVocab, "-bytelen", "9"}, exitUsage},
+		{[]string{"encode", "-vocab", testVocab, "-mapper", "unknown"}, exitUsage},
+		{[]string{"encode", "-undefined"}, exitUsage},
+		{[]string{"decode"}, exitUsage},
+		{[]string{"encode", "-vocab", testVocab, "nonexistent"}, exitError},
+	}
+
+	for idx, test := range tests {
+		if status, _, _ := runTest(test.args, ""); test.status != status {
+			t.Errorf("[%d] expected %d, but got %d", idx, test.status, status)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	words := "bcd\na\nijkl\ndefgh\n"
+
+	for _, mapper := range mapperNames {
+		status, stdout, stderr := runTest([]string{"encode", "-vocab", testVocab, "-mapper", mapper}, words)
+		if status != exitOK {
+			t.Errorf("[%s] expected %d, but got %d: %s", mapper, exitOK, status, stderr)
+			continue
+		}
+		if expected := string([]byte{43, 0, 61, 47}); expected != stdout {
+			t.Errorf("[%s] expected %v, but got %v", mapper, []byte(expected), []byte(stdout))
+		}
+
+		status, out, stderr := runTest([]string{"decode", "-vocab", testVocab, "-mapper", mapper}, stdout)
+		if status != exitOK {
+			t.Errorf("[%s] expected %d, but got %d: %s", mapper, exitOK, status, stderr)
+			continue
+		}
+		if words != out {
+			t.Errorf("[%s] expected %q, but got %q", mapper, words, out)
+		}
+	}
+}
+
+func TestEncode_ByteLen(t *testing.T) {
+	status, stdout, _ := runTest([]string{"encode", "-vocab", testVocab, "-bytelen", "3"}, "bcd\n")
+	if status != exitOK {
+		t.Fatalf("expected %d, but got %d", exitOK, status)
+	}
+	if expected := string([]byte{0, 0, 43}); expected != stdout {
+		t.Errorf("expected %v, but got %v", []byte(expected), []byte(stdout))
+	}
+}
+
+func TestEncode_Missing(t *testing.T) {
+	status, stdout, stderr := runTest([]string{"encode", "-vocab", testVocab}, "bcd\nxyz\na\n\nijk\n")
+	if status != exitError {
+		t.Errorf("expected %d, but got %d", exitError, status)
+	}
+	if expected := string([]byte{43, 0, 57}); expected != stdout {
+		t.Errorf("expected %v, but got %v", []byte(expected), []byte(stdout))
+	}
+
+	expected := `<stdin>:2: word not in vocabulary: "xyz"
+<stdin>:4: word not in vocabulary: ""
+nwenc encode: 2 words not in vocabulary
+`
+	if expected != stderr {
+		t.Errorf("expected %q, but got %q", expected, stderr)
+	}
+}
+
+func TestEncode_Files(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nwenc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file1, file2 := filepath.Join(dir, "1.txt"), filepath.Join(dir, "2.txt")
+	if err := ioutil.WriteFile(file1, []byte("a\nbcd\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(file2, []byte("zzz\nijk"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, stdout, stderr := runTest([]string{"encode", "-vocab", testVocab, file1, file2}, "")
+	if status != exitError {
+		t.Errorf("expected %d, but got %d", exitError, status)
+	}
+	if expected := string([]byte{0, 43, 57}); expected != stdout {
+		t.Errorf("expected %v, but got %v", []byte(expected), []byte(stdout))
+	}
+	if !strings.HasPrefix(stderr, file2+`:1: word not in vocabulary: "zzz"`) {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	status, stdout, stderr := runTest([]string{"decode", "-vocab", testVocab}, string([]byte{43, 1, 0}))
+	if status != exitError {
+		t.Errorf("expected %d, but got %d", exitError, status)
+	}
+	if expected := "bcd\na\n"; expected != stdout {
+		t.Errorf("expected %q, but got %q", expected, stdout)
+	}
+	expected := `<stdin>: record 1 (byte 1): offset cannot decode: 1
+nwenc decode: 1 codes not in vocabulary
+`
+	if expected != stderr {
+		t.Errorf("expected %q, but got %q", expected, stderr)
+	}
+
+	// truncated
+	status, stdout, stderr = runTest([]string{"decode", "-vocab", testVocab, "-bytelen", "2"}, string([]byte{0, 43, 0}))
+	if status != exitError {
+		t.Errorf("expected %d, but got %d", exitError, status)
+	}
+	if expected := "bcd\n"; expected != stdout {
+		t.Errorf("expected %q, but got %q", expected, stdout)
+	}
+	if !strings.Contains(stderr, "unexpected EOF") {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+}
+
+func TestDump(t *testing.T) {
+	// "bcd", "a", the middle of "a...i", out of range
+	codes := string([]byte{43, 0, 4, 255})
+
+	tests := []struct {
+		args   []string
+		stdout string
+	}{
+		{
+			[]string{"dump", "-vocab", testVocab},
+			"0\t0\t2b\t43\t\"bcd\"\n" +
+				"1\t1\t00\t0\t\"a\"\n" +
+				"2\t2\t04\t4\t\"\"\n" +
+				"3\t3\tff\t255\t\"\"\n",
+		},
+		{
+			[]string{"dump", "-vocab", testVocab, "-highlight", "-start", "1"},
+			"1\t1\t00\t0\t\"a\"\n" +
+				"2\t2\t04\t4\t\"\"\t!mid-line\n" +
+				"3\t3\tff\t255\t\"\"\t!out of range\n",
+		},
+		{
+			[]string{"dump", "-vocab", testVocab, "-start", "1", "-n", "1"},
+			"1\t1\t00\t0\t\"a\"\n",
+		},
+		{
+			[]string{"dump", "-vocab", testVocab, "-start", "5"},
+			"",
+		},
+		{
+			[]string{"dump", "-vocab", testVocab, "-json", "-highlight", "-n", "3"},
+			`{"index":0,"pos":0,"code":"2b","offset":43,"word":"bcd"}` + "\n" +
+				`{"index":1,"pos":1,"code":"00","offset":0,"word":"a"}` + "\n" +
+				`{"index":2,"pos":2,"code":"04","offset":4,"word":"","status":"mid-line"}` + "\n",
+		},
+		{
+			[]string{"dump", "-vocab", testVocab, "-bytelen", "2", "-n", "1"},
+			"0\t0\t2b00\t11008\t\"\"\n",
+		},
+	}
+
+	for idx, test := range tests {
+		status, stdout, stderr := runTest(test.args, codes)
+		if status != exitOK {
+			t.Errorf("[%d] expected %d, but got %d: %s", idx, exitOK, status, stderr)
+			continue
+		}
+		if test.stdout != stdout {
+			t.Errorf("[%d] expected %q, but got %q", idx, test.stdout, stdout)
+		}
+	}
+}
+
+func TestDump_Invalid(t *testing.T) {
+	// the newline of "a\n" is in the middle of a line
+	status, stdout, _ := runTest([]string{"dump", "-vocab", testVocab, "-highlight"}, string([]byte{1}))
+	if status != exitOK {
+		t.Errorf("expected %d, but got %d", exitOK, status)
+	}
+	if expected := "0\t0\t01\t1\t\"\"\t!mid-line\n"; expected != stdout {
+		t.Errorf("expected %q, but got %q", expected, stdout)
+	}
+
+	// truncated
+	status, stdout, stderr := runTest([]string{"dump", "-vocab", testVocab, "-bytelen", "2"}, string([]byte{0, 43, 0}))
+	if status != exitError {
+		t.Errorf("expected %d, but got %d", exitError, status)
+	}
+	if expected := "0\t0\t002b\t43\t\"bcd\"\n"; expected != stdout {
+		t.Errorf("expected %q, but got %q", expected, stdout)
+	}
+	if expected := "nwenc dump: <stdin>: record 1 (byte 2): unexpected EOF\n"; expected != stderr {
+		t.Errorf("expected %q, but got %q", expected, stderr)
+	}
+
+	for idx, args := range [][]string{
+		{"dump", "-vocab", testVocab, "-start", "-1"},
+		{"dump", "-vocab", testVocab, "a", "b"},
+	} {
+		if status, _, _ := runTest(args, ""); status != exitUsage {
+			t.Errorf("[%d] expected %d, but got %d", idx, exitUsage, status)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	status, stdout, stderr := runTest([]string{"verify", testVocab}, "")
+	if status != exitOK {
+		t.Errorf("expected %d, but got %d: %s", exitOK, status, stderr)
+	}
+	if expected := testVocab + ": 8 lines, 66 bytes, minimum byte length 1\n"; expected != stdout {
+		t.Errorf("expected %q, but got %q", expected, stdout)
+	}
+	if stderr != "" {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+}
+
+func TestVerify_Invalid(t *testing.T) {
+	long := strings.Repeat("z", bufio.MaxScanTokenSize+1)
+	tests := []struct {
+		in     string
+		status int
+		stdout string
+		stderr string
+	}{
+		{
+			"a\nb\n\nc\nc\nb\nd\r\ne\xff\n" + long + "\n",
+			exitError,
+			"<stdin>: 9 lines, 65555 bytes, minimum byte length 3\n",
+			"<stdin>:3: empty line\n" +
+				"<stdin>:5: duplicate: \"c\"\n" +
+				"<stdin>:6: not sorted: \"b\" after \"c\"\n" +
+				"<stdin>:7: CRLF line ending\n" +
+				"<stdin>:8: invalid UTF-8: \"e\\xff\"\n" +
+				"<stdin>:9: line too long: 65537 bytes\n" +
+				"nwenc verify: 6 errors\n",
+		},
+		{
+			// the last newline can be omitted
+			"a\nbc",
+			exitOK,
+			"<stdin>: 2 lines, 4 bytes, minimum byte length 1\n",
+			"",
+		},
+		{
+			"a\nb\xff\nc\n",
+			exitError,
+			"<stdin>: 3 lines, 7 bytes, minimum byte length 1\n",
+			"<stdin>:2: invalid UTF-8: \"b\\xff\"\n" +
+				"nwenc verify: 1 errors\n",
+		},
+		{
+			"",
+			exitOK,
+			"<stdin>: 0 lines, 0 bytes, minimum byte length 1\n",
+			"",
+		},
+		{
+			// bufio.Scanner cannot read a line of MaxScanTokenSize bytes with '\n'
+			strings.Repeat("z", bufio.MaxScanTokenSize) + "\n",
+			exitError,
+			"<stdin>: 1 lines, 65537 bytes, minimum byte length 3\n",
+			"<stdin>:1: line too long: 65536 bytes\n" +
+				"nwenc verify: 1 errors\n",
+		},
+		{
+			strings.Repeat("z", bufio.MaxScanTokenSize-1) + "\n",
+			exitOK,
+			"<stdin>: 1 lines, 65536 bytes, minimum byte length 2\n",
+			"",
+		},
+	}
+
+	for idx, test := range tests {
+		status, stdout, stderr := runTest([]string{"verify"}, test.in)
+		if test.status != status {
+			t.Errorf("[%d] expected %d, but got %d", idx, test.status, status)
+		}
+		if test.stdout != stdout {
+			t.Errorf("[%d] expected %q, but got %q", idx, test.stdout, stdout)
+		}
+		if test.stderr != stderr {
+			t.Errorf("[%d] expected %q, but got %q", idx, test.stderr, stderr)
+		}
+	}
+}
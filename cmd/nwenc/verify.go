@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/high-moctane/nwenc"
+)
+
+// runVerify runs the verify command.
+func runVerify(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("nwenc verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: nwenc verify [file ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	errs := 0
+	err := forEachInput(fs.Args(), stdin, func(in input) error {
+		st, err := verifyVocab(in, stderr)
+		if err != nil {
+			return fmt.Errorf("%s: %v", in.name, err)
+		}
+		fmt.Fprintf(stdout, "%s: %d lines, %d bytes, minimum byte length %d\n",
+			in.name, st.lines, st.size, nwenc.MinByteLen(st.size))
+		errs += st.errs
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "nwenc verify: %v\n", err)
+		return exitError
+	}
+
+	if errs > 0 {
+		fmt.Fprintf(stderr, "nwenc verify: %d errors\n", errs)
+		return exitError
+	}
+	return exitOK
+}
+
+// verifyStats are the statistics of a verified vocabulary.
+type verifyStats struct {
+	lines int64
+	size  int64
+	errs  int
+}
+
+// verifyVocab reads the vocabulary of in and reports the problems to w.
+// The OffsetMappers need the lines sorted in byte order without duplicates and empty lines.
+// A '\r' before '\n', a line of bufio.MaxScanTokenSize bytes or longer, which bufio.Scanner
+// cannot read with '\n', and invalid UTF-8 are errors too because the OffsetMappers do not
+// decode them as they are.
+func verifyVocab(in input, w io.Writer) (st verifyStats, err error) {
+	report := func(format string, a ...interface{}) {
+		fmt.Fprintf(w, "%s:%d: %s\n", in.name, st.lines, fmt.Sprintf(format, a...))
+		st.errs++
+	}
+
+	r := bufio.NewReader(in.r)
+	var prev []byte
+	for {
+		line, rerr := r.ReadBytes('\n')
+		if len(line) == 0 && rerr == io.EOF {
+			return st, nil
+		}
+		if rerr != nil && rerr != io.EOF {
+			return st, rerr
+		}
+		st.lines++
+		st.size += int64(len(line))
+		line = bytes.TrimSuffix(line, []byte("\n"))
+
+		switch {
+		case len(line) == 0:
+			report("empty line")
+		case st.lines > 1 && bytes.Equal(line, prev):
+			report("duplicate: %q", line)
+		case st.lines > 1 && bytes.Compare(line, prev) < 0:
+			report("not sorted: %q after %q", line, prev)
+		}
+		if bytes.HasSuffix(line, []byte("\r")) {
+			report("CRLF line ending")
+		}
+		if len(line) >= bufio.MaxScanTokenSize {
+			report("line too long: %d bytes", len(line))
+		}
+		if !utf8.Valid(line) {
+			report("invalid UTF-8: %q", line)
+		}
+
+		prev = append(prev[:0], line...)
+	}
+}
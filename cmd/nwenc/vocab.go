@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/high-moctane/nwenc"
+)
+
+// The names of the mappers.
+var mapperNames = []string{"allread", "seek", "cached", "mmap", "sparse"}
+
+// sparseInterval is the interval of the lines which the sparse mapper keeps.
+const sparseInterval = 64
+
+// vocabFlags are the flags about the vocabulary file.
+type vocabFlags struct {
+	path    string
+	byteLen int
+	mapper  string
+}
+
+// register registers the flags to fs.
+func (vf *vocabFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&vf.path, "vocab", "", "the sorted vocabulary `file` (required)")
+	fs.IntVar(&vf.byteLen, "bytelen", 0, "the byte length of a code (default: the minimum for the vocabulary)")
+	fs.StringVar(&vf.mapper, "mapper", "allread", fmt.Sprintf("the OffsetMapper implementation: %v", mapperNames))
+}
+
+// vocab is an opened vocabulary file.
+type vocab struct {
+	om      nwenc.OffsetMapper
+	r       io.ReaderAt
+	size    int64
+	byteLen int
+	close   func() error
+}
+
+// open opens the vocabulary file and its OffsetMapper.
+func (vf *vocabFlags) open() (*vocab, error) {
+	if vf.path == "" {
+		return nil, fmt.Errorf("-vocab is required")
+	}
+
+	f, err := os.Open(vf.path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	v := &vocab{r: f, size: info.Size(), byteLen: vf.byteLen, close: f.Close}
+	if v.byteLen == 0 {
+		v.byteLen = nwenc.MinByteLen(v.size)
+	}
+	if v.byteLen < 1 || 8 < v.byteLen {
+		f.Close()
+		return nil, fmt.Errorf("invalid byte length: %d", v.byteLen)
+	}
+
+	v.om, err = openMapper(vf.mapper, f, v.size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if c, ok := v.om.(io.Closer); ok {
+		v.close = func() error {
+			c.Close()
+			return f.Close()
+		}
+	}
+	return v, nil
+}
+
+// openMapper returns the OffsetMapper of name.
+func openMapper(name string, f *os.File, size int64) (nwenc.OffsetMapper, error) {
+	switch name {
+	case "allread":
+		return nwenc.NewAllReadOffsetMapper(f)
+	case "seek":
+		return nwenc.NewSeekOffsetMapper(f, size), nil
+	case "cached":
+		return nwenc.NewCachedSeekOffsetMapper(f, size), nil
+	case "mmap":
+		return nwenc.NewMmapOffsetMapper(f)
+	case "sparse":
+		return nwenc.NewSparseOffsetMapper(f, size, sparseInterval)
+	default:
+		return nil, fmt.Errorf("unknown mapper: %s", name)
+	}
+}
@@ -0,0 +1,36 @@
+package nwenc
+
+import "bytes"
+
+// Compare compares the keys a and b which Encoder encodes with the same byte length and
+// returns -1, 0 or +1 in the same way as bytes.Compare. A key may be the concatenation
+// of several offsets, such as an n-gram.
+//
+// Because the offsets are encoded in big endian and the vocabulary file is sorted,
+// the order of the keys is the order of the words. So sorted stores and merge tools
+// can compare the encoded keys without decoding them.
+func Compare(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
+// PrefixRange returns the range [lo, hi) of the encoded keys of the words which start with
+// prefix. The ps must search the sorted vocabulary file. A key k is in the range when
+// Compare(lo, k) <= 0 && (hi == nil || Compare(k, hi) < 0). The hi is nil when the range
+// has no upper bound in the byte length.
+// When no word starts with prefix, it will return OffsetEncodeError.
+func (e *Encoder) PrefixRange(ps PrefixSearcher, prefix string) (lo, hi []byte, err error) {
+	first, last, _, err := ps.PrefixSearch(prefix)
+	if err != nil {
+		return
+	}
+
+	if err = e.checkRange(first); err != nil {
+		return
+	}
+	lo = e.AppendEncode(nil, first)
+	// no word begins between last and last+1
+	if e.Fits(last + 1) {
+		hi = e.AppendEncode(nil, last+1)
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package nwenc
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readTestWords returns the words of testdata/words.txt.
+func readTestWords(t *testing.T) []string {
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	var words []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		words = append(words, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return words
+}
+
+func TestCompare(t *testing.T) {
+	words := readTestWords(t)
+	om, err := NewAllReadOffsetMapper(strings.NewReader(strings.Join(words, "\n") + "\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	enc, _ := NewEncoder(2)
+
+	// encode returns the key of the words.
+	encode := func(words ...string) []byte {
+		var key []byte
+		for _, word := range words {
+			offset, err := om.OffsetEncode(word)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			key = enc.AppendEncode(key, offset)
+		}
+		return key
+	}
+
+	for _, a := range words {
+		for _, b := range words {
+			if expected, got := strings.Compare(a, b), Compare(encode(a), encode(b)); expected != got {
+				t.Errorf("(%q, %q) expected %d, but got %d", a, b, expected, got)
+			}
+			// bigrams which begin with the same word are ordered by the second word
+			for _, c := range words {
+				if expected, got := strings.Compare(b, c), Compare(encode(a, b), encode(a, c)); expected != got {
+					t.Errorf("(%q, %q, %q) expected %d, but got %d", a, b, c, expected, got)
+				}
+			}
+		}
+	}
+}
+
+func TestEncoder_PrefixRange(t *testing.T) {
+	words := readTestWords(t)
+	om, err := NewAllReadOffsetMapper(strings.NewReader(strings.Join(words, "\n") + "\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type outType struct {
+		lo, hi []byte
+		err    bool
+	}
+	tests := []struct {
+		byteLen int
+		prefix  string
+		out     outType
+	}{
+		{1, "", outType{[]byte{0}, []byte{62}, false}},
+		{1, "a", outType{[]byte{0}, []byte{39}, false}},
+		{1, "ab", outType{[]byte{38}, []byte{39}, false}},
+		{1, "de", outType{[]byte{47}, []byte{54}, false}},
+		{1, "ijk", outType{[]byte{57}, []byte{62}, false}},
+		{2, "ijk", outType{[]byte{0, 57}, []byte{0, 62}, false}},
+		{1, "c", outType{nil, nil, true}},
+		{1, "ijklm", outType{nil, nil, true}},
+	}
+
+	for idx, test := range tests {
+		enc, _ := NewEncoder(test.byteLen)
+		lo, hi, err := enc.PrefixRange(om, test.prefix)
+		if (err != nil) != test.out.err {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		if Compare(test.out.lo, lo) != 0 || Compare(test.out.hi, hi) != 0 {
+			t.Errorf("[%d] expected [%v, %v), but got [%v, %v)", idx, test.out.lo, test.out.hi, lo, hi)
+		}
+		if err != nil {
+			continue
+		}
+
+		// every word is in the range iff it starts with prefix
+		for _, word := range words {
+			offset, _ := om.OffsetEncode(word)
+			key := enc.AppendEncode(nil, offset)
+			in := Compare(lo, key) <= 0 && (hi == nil || Compare(key, hi) < 0)
+			if expected := strings.HasPrefix(word, test.prefix); expected != in {
+				t.Errorf("[%d] %q expected %v, but got %v", idx, word, expected, in)
+			}
+		}
+	}
+}
+
+func TestEncoder_PrefixRange_Unbounded(t *testing.T) {
+	// the last word is at 0xFF
+	text := strings.Repeat("a", 0xFE) + "\nb\n"
+	om, err := NewAllReadOffsetMapper(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	enc, _ := NewEncoder(1)
+
+	lo, hi, err := enc.PrefixRange(om, "b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if Compare([]byte{0xFF}, lo) != 0 || hi != nil {
+		t.Errorf("expected [%v, %v), but got [%v, %v)", []byte{0xFF}, nil, lo, hi)
+	}
+}
@@ -1,12 +1,18 @@
 package nwenc
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
+	"sync"
 )
 
+// ByteDecoder is the interface which decodes bytes to an int64 offset or a string.
+// Decoder and VarintDecoder implement it.
+type ByteDecoder interface {
+	Decode(r io.Reader) (offset int64, err error)
+	DecodeString(r io.Reader, od OffsetDecoder) (s string, err error)
+}
+
 // Decoder decodes bytes to an int64 or a string.
 type Decoder struct {
 	l int // byte length
@@ -21,19 +27,46 @@ func NewDecoder(byteLen int) (*Decoder, error) {
 	return &Decoder{l: byteLen}, nil
 }
 
-// Decode reads r and decodes to the offset.
+// decodeBufPool is the pool of the buffers of Decode. The buffer passed to io.Reader escapes
+// to the heap, so it is reused instead of allocated for each call.
+var decodeBufPool = sync.Pool{
+	New: func() interface{} { return new([8]byte) },
+}
+
+// Decode reads r and decodes to the offset. It reads r until byteLen bytes are read, so
+// a short read of a pipe or a network connection is not an error.
+// It returns io.EOF only when no byte is read and io.ErrUnexpectedEOF when the offset is truncated.
+// Use StreamDecoder to read many offsets from a slow reader.
 func (d *Decoder) Decode(r io.Reader) (offset int64, err error) {
-	// translate into 8 bytes int64 value
-	buf := make([]byte, 8)
-	var n int
-	if n, err = r.Read(buf[8-d.l:]); err != nil {
+	buf := decodeBufPool.Get().(*[8]byte)
+	defer decodeBufPool.Put(buf)
+
+	b := buf[:d.l]
+	if _, err = io.ReadFull(r, b); err != nil {
 		return
 	}
-	if n != d.l {
-		return 0, io.ErrUnexpectedEOF
+	return d.decodeBytes(b), nil
+}
+
+// DecodeInto decodes the offsets in src into dst and returns the number of decoded offsets.
+// It decodes min(len(dst), len(src)/byteLen) offsets without allocation.
+// When dst has room but src ends in the middle of an offset, it will return io.ErrUnexpectedEOF.
+func (d *Decoder) DecodeInto(dst []int64, src []byte) (n int, err error) {
+	for n < len(dst) && len(src) >= d.l {
+		dst[n] = d.decodeBytes(src[:d.l])
+		src = src[d.l:]
+		n++
+	}
+	if n < len(dst) && len(src) > 0 {
+		err = io.ErrUnexpectedEOF
 	}
-	if err = binary.Read(bytes.NewBuffer(buf), binary.BigEndian, &offset); err != nil {
-		return
+	return
+}
+
+// decodeBytes decodes the big endian bytes of b, whose length is the byte length.
+func (d *Decoder) decodeBytes(b []byte) (offset int64) {
+	for _, c := range b {
+		offset = offset<<8 | int64(c)
 	}
 	return
 }
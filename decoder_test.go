@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"testing/iotest"
 )
 
 func TestDecode(t *testing.T) {
@@ -110,3 +111,121 @@ func TestDecodeString(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeInto(t *testing.T) {
+	type outType struct {
+		offsets []int64
+		err     error
+	}
+	tests := []struct {
+		src []byte
+		n   int
+		out outType
+	}{
+		{
+			[]byte{},
+			2,
+			outType{[]int64{}, nil},
+		},
+		{
+			[]byte{16, 255, 5, 3, 241, 16},
+			2,
+			outType{[]int64{0x10FF05, 0x03F110}, nil},
+		},
+		{
+			[]byte{16, 255, 5, 3, 241, 16},
+			3,
+			outType{[]int64{0x10FF05, 0x03F110}, nil},
+		},
+		{
+			[]byte{16, 255, 5, 3, 241, 16},
+			1,
+			outType{[]int64{0x10FF05}, nil},
+		},
+		{
+			[]byte{16, 255, 5, 3, 241},
+			2,
+			outType{[]int64{0x10FF05}, io.ErrUnexpectedEOF},
+		},
+		{
+			// the rest is not read
+			[]byte{16, 255, 5, 3, 241},
+			1,
+			outType{[]int64{0x10FF05}, nil},
+		},
+	}
+
+	for idx, test := range tests {
+		dec, _ := NewDecoder(3)
+		dst := make([]int64, test.n)
+		n, err := dec.DecodeInto(dst, test.src)
+		if test.out.err != err {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.offsets, dst[:n]) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.offsets, dst[:n])
+		}
+	}
+}
+
+func TestDecodeInto_Allocs(t *testing.T) {
+	dec, _ := NewDecoder(3)
+	src := bytes.Repeat([]byte{16, 255, 5}, 1000)
+	dst := make([]int64, 1000)
+
+	if n := testing.AllocsPerRun(100, func() {
+		dec.DecodeInto(dst, src)
+	}); n != 0 {
+		t.Errorf("expected %d allocs, but got %v", 0, n)
+	}
+}
+
+func TestDecode_Allocs(t *testing.T) {
+	dec, _ := NewDecoder(3)
+	src := []byte{16, 255, 5}
+	r := bytes.NewReader(src)
+
+	if n := testing.AllocsPerRun(100, func() {
+		r.Reset(src)
+		dec.Decode(r)
+	}); n != 0 {
+		t.Errorf("expected %d allocs, but got %v", 0, n)
+	}
+}
+
+func BenchmarkDecoder_Decode(b *testing.B) {
+	dec, _ := NewDecoder(3)
+	src := []byte{16, 255, 5}
+	r := bytes.NewReader(src)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(src)
+		dec.Decode(r)
+	}
+}
+
+func BenchmarkDecoder_DecodeInto(b *testing.B) {
+	dec, _ := NewDecoder(3)
+	src := bytes.Repeat([]byte{16, 255, 5}, 4096)
+	dst := make([]int64, 4096)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec.DecodeInto(dst, src)
+	}
+}
+
+func TestDecode_ShortRead(t *testing.T) {
+	dec, _ := NewDecoder(3)
+	r := iotest.OneByteReader(bytes.NewReader([]byte{16, 255, 5, 3, 241}))
+
+	if offset, err := dec.Decode(r); offset != 0x10FF05 || err != nil {
+		t.Errorf("expected (%d, %v), but got (%d, %v)", 0x10FF05, nil, offset, err)
+	}
+	if _, err := dec.Decode(r); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, but got %v", io.ErrUnexpectedEOF, err)
+	}
+	if _, err := dec.Decode(r); err != io.EOF {
+		t.Errorf("expected %v, but got %v", io.EOF, err)
+	}
+}
@@ -4,8 +4,16 @@ Package nwenc is the implementation of encoder and decoder for the nextword data
 The OffsetEncoder and OffsetDecoder can encode/decode between an int64 offset value
 and a string. The offset means the byte offset in a file that the string appears.
 There are several implementation for OffsetMapper. They have different performance.
+The OffsetMappers also implement PrefixSearcher, which finds the range of strings
+starting with a prefix. The package nwenctest tests an implementation of OffsetMapper
+by the edge cases which all of them must follow.
 
 Encoder and Decoder can encode/decode between an int64 offset value and bytes.
+Writer and Reader read/write an encoded file whose header records the codec and
+the checksum of the vocabulary file, so that the file cannot be decoded with another one.
+BlockWriter and BlockReader frame the encoded offsets into blocks with checksums,
+so that a corrupt block is reported and skipped.
+RecordCodec encodes/decodes a struct of several words and integers by its struct tags.
 
 OffsetEncoder/OffsetDecoder example:
 
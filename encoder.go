@@ -1,12 +1,17 @@
 package nwenc
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
 )
 
+// ByteEncoder is the interface which encodes an int64 offset or a string to bytes.
+// Encoder and VarintEncoder implement it.
+type ByteEncoder interface {
+	Encode(w io.Writer, offset int64) error
+	EncodeString(w io.Writer, oe OffsetEncoder, s string) error
+}
+
 // Encoder encodes an int64 to bytes.
 type Encoder struct {
 	l int // byte length
@@ -22,20 +27,86 @@ func NewEncoder(byteLen int) (*Encoder, error) {
 	return &Encoder{l: byteLen}, nil
 }
 
+// OffsetRangeError is returned when an offset is negative or does not fit in the byte length.
+type OffsetRangeError struct {
+	offset  int64
+	byteLen int
+}
+
+func (e *OffsetRangeError) Error() string {
+	return fmt.Sprintf("offset out of range of %d bytes: %v", e.byteLen, e.offset)
+}
+
+// MinByteLen returns the minimum byte length which can encode all offsets in a text file
+// of size bytes. The result is 1 <= byteLen <= 8, so it can be passed to NewEncoder.
+func MinByteLen(size int64) int {
+	byteLen := 1
+	for byteLen < 8 && size-1 >= 1<<uint(8*byteLen) {
+		byteLen++
+	}
+	return byteLen
+}
+
 // Encode encodes offset to bytes and writes it to w.
+// When offset does not fit in the byte length, it will return OffsetRangeError.
 func (e *Encoder) Encode(w io.Writer, offset int64) error {
-	buf := new(bytes.Buffer)
-	if err := binary.Write(buf, binary.BigEndian, offset); err != nil {
+	if err := e.checkRange(offset); err != nil {
 		return err
 	}
-	b := buf.Bytes()
-	// write only e.l length of bytes
-	if _, err := w.Write(b[8-e.l:]); err != nil {
+	if _, err := w.Write(e.AppendEncode(make([]byte, 0, 8), offset)); err != nil {
 		return err
 	}
 	return nil
 }
 
+// AppendEncode appends the encoded bytes of offset to dst and returns the extended slice.
+// It does not allocate when dst has enough capacity.
+// It panics with *OffsetRangeError when offset does not fit in the byte length,
+// so check offset by Fits first, or use Encode or EncodeSlice which return the error.
+func (e *Encoder) AppendEncode(dst []byte, offset int64) []byte {
+	if err := e.checkRange(offset); err != nil {
+		panic(err)
+	}
+
+	// big endian
+	for i := e.l - 1; i >= 0; i-- {
+		dst = append(dst, byte(offset>>uint(8*i)))
+	}
+	return dst
+}
+
+// Fits reports whether offset fits in the byte length, that is, AppendEncode does not panic.
+func (e *Encoder) Fits(offset int64) bool {
+	return offset >= 0 && (e.l == 8 || offset < 1<<uint(8*e.l))
+}
+
+// checkRange returns OffsetRangeError when offset does not fit in the byte length.
+func (e *Encoder) checkRange(offset int64) error {
+	if !e.Fits(offset) {
+		return &OffsetRangeError{offset: offset, byteLen: e.l}
+	}
+	return nil
+}
+
+// EncodeSlice appends the encoded bytes of all offsets to dst and returns the extended slice.
+// It grows dst at most once. When an offset does not fit in the byte length, it will return
+// dst with the offsets before it and OffsetRangeError.
+func (e *Encoder) EncodeSlice(dst []byte, offsets []int64) ([]byte, error) {
+	if n := len(dst) + e.l*len(offsets); n > cap(dst) {
+		grown := make([]byte, len(dst), n)
+		copy(grown, dst)
+		dst = grown
+	}
+
+	for _, offset := range offsets {
+		if err := e.checkRange(offset); err != nil {
+			return dst, err
+		}
+		dst = e.AppendEncode(dst, offset)
+	}
+	return dst, nil
+}
+
 // EncodeString encodes s to bytes and writes it to w.
 func (e *Encoder) EncodeString(w io.Writer, oe OffsetEncoder, s string) error {
 	offset, err := oe.OffsetEncode(s)
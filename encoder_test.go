@@ -99,3 +99,210 @@ func TestEncodeString(t *testing.T) {
 		}
 	}
 }
+
+func TestEncode_OutOfRange(t *testing.T) {
+	type inType struct {
+		byteLen int
+		offset  int64
+	}
+	type outType struct {
+		buf []byte
+		err error
+	}
+	tests := []struct {
+		in  inType
+		out outType
+	}{
+		{
+			inType{1, 0xFF},
+			outType{[]byte{0xFF}, nil},
+		},
+		{
+			inType{1, 0x100},
+			outType{nil, &OffsetRangeError{offset: 0x100, byteLen: 1}},
+		},
+		{
+			inType{3, 0xFFFFFF},
+			outType{[]byte{0xFF, 0xFF, 0xFF}, nil},
+		},
+		{
+			inType{3, 0x1000000},
+			outType{nil, &OffsetRangeError{offset: 0x1000000, byteLen: 3}},
+		},
+		{
+			inType{3, -1},
+			outType{nil, &OffsetRangeError{offset: -1, byteLen: 3}},
+		},
+		{
+			inType{8, 0x7FFFFFFFFFFFFFFF},
+			outType{[]byte{0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, nil},
+		},
+		{
+			inType{8, -1},
+			outType{nil, &OffsetRangeError{offset: -1, byteLen: 8}},
+		},
+	}
+
+	for idx, test := range tests {
+		buf := new(bytes.Buffer)
+		enc, err := NewEncoder(test.in.byteLen)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v", idx, err)
+		}
+
+		if expected, got := test.out.err == nil, enc.Fits(test.in.offset); expected != got {
+			t.Errorf("[%d] expected %v, but got %v", idx, expected, got)
+		}
+
+		err = enc.Encode(buf, test.in.offset)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+		}
+		if err != nil {
+			if buf.Len() != 0 {
+				t.Errorf("[%d] expected no output, but got %v", idx, buf.Bytes())
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(test.out.buf, buf.Bytes()) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.buf, buf.Bytes())
+		}
+	}
+}
+
+func TestMinByteLen(t *testing.T) {
+	tests := []struct {
+		in  int64
+		out int
+	}{
+		{0, 1},
+		{1, 1},
+		{0x100, 1},
+		{0x101, 2},
+		{0x10000, 2},
+		{0x10001, 3},
+		{0x1000000, 3},
+		{0x1000001, 4},
+		{0x7FFFFFFFFFFFFFFF, 8},
+	}
+
+	for idx, test := range tests {
+		byteLen := MinByteLen(test.in)
+		if test.out != byteLen {
+			t.Errorf("[%d] expected %d, but got %d", idx, test.out, byteLen)
+		}
+		if _, err := NewEncoder(byteLen); err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+		}
+	}
+}
+
+func TestAppendEncode(t *testing.T) {
+	type outType struct {
+		buf []byte
+		err error
+	}
+	tests := []struct {
+		in  []int64
+		out outType
+	}{
+		{
+			[]int64{2},
+			outType{[]byte{0xAA, 0, 0, 2}, nil},
+		},
+		{
+			[]int64{0x10FF05, 0x03F110},
+			outType{[]byte{0xAA, 16, 255, 5, 3, 241, 16}, nil},
+		},
+		{
+			[]int64{0x10FF05, 0x1000000, 2},
+			outType{[]byte{0xAA, 16, 255, 5}, &OffsetRangeError{offset: 0x1000000, byteLen: 3}},
+		},
+	}
+
+	for idx, test := range tests {
+		enc, _ := NewEncoder(3)
+		buf := []byte{0xAA}
+		var err error
+		for _, offset := range test.in {
+			if buf, err = appendEncode(enc, buf, offset); err != nil {
+				break
+			}
+		}
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.buf, buf) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.buf, buf)
+		}
+
+		buf, err = enc.EncodeSlice([]byte{0xAA}, test.in)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.buf, buf) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.buf, buf)
+		}
+	}
+}
+
+// appendEncode calls AppendEncode and returns the value of its panic as the error.
+func appendEncode(enc *Encoder, dst []byte, offset int64) (buf []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			buf, err = dst, r.(error)
+		}
+	}()
+	return enc.AppendEncode(dst, offset), nil
+}
+
+func TestEncodeSlice_Allocs(t *testing.T) {
+	enc, _ := NewEncoder(3)
+	offsets := make([]int64, 1000)
+	for i := range offsets {
+		offsets[i] = int64(i) * 1000
+	}
+	buf := make([]byte, 0, 3*len(offsets))
+
+	if n := testing.AllocsPerRun(100, func() {
+		buf = enc.AppendEncode(buf[:0], 0x10FF05)
+	}); n != 0 {
+		t.Errorf("AppendEncode: expected %d allocs, but got %v", 0, n)
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		buf, _ = enc.EncodeSlice(buf[:0], offsets)
+	}); n != 0 {
+		t.Errorf("EncodeSlice: expected %d allocs, but got %v", 0, n)
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		_, _ = enc.EncodeSlice(nil, offsets)
+	}); n != 1 {
+		t.Errorf("EncodeSlice: expected %d allocs, but got %v", 1, n)
+	}
+}
+
+func BenchmarkEncoder_Encode(b *testing.B) {
+	enc, _ := NewEncoder(3)
+	buf := new(bytes.Buffer)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		enc.Encode(buf, int64(i&0xFFFFFF))
+	}
+}
+
+func BenchmarkEncoder_EncodeSlice(b *testing.B) {
+	enc, _ := NewEncoder(3)
+	offsets := make([]int64, 4096)
+	for i := range offsets {
+		offsets[i] = int64(i) * 1000
+	}
+	buf := make([]byte, 0, 3*len(offsets))
+	b.SetBytes(int64(cap(buf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ = enc.EncodeSlice(buf[:0], offsets)
+	}
+}
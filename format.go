@@ -0,0 +1,381 @@
+package nwenc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The encoded file consists of the header and the encoded offsets.
+// All integers of the header are big endian.
+// When the count is unknown, a CodecBits file ends with one byte of the number of the padding
+// bits of the last byte, so that the padding is not decoded as offsets.
+//
+//	magic     [4]byte  "NWEN"
+//	version   uint16   formatVersion
+//	codec     uint8    Codec
+//	width     uint8    the byte length of CodecFixed, the bit length of CodecBits or 0
+//	count     int64    the number of offsets or -1 when unknown
+//	size      int64    the total bytes of the vocabulary file
+//	checksum  uint32   CRC-32C of the vocabulary file
+//	crc       uint32   CRC-32C of all of the above
+const (
+	formatMagic   = "NWEN"
+	formatVersion = 1
+)
+
+// HeaderSize is the byte length of the header of the encoded file.
+const HeaderSize = 4 + 2 + 1 + 1 + 8 + 8 + 4 + 4
+
+// ErrVocabMismatch is returned when the encoded file was written with another vocabulary file.
+var ErrVocabMismatch = errors.New("encoded file does not match the vocabulary")
+
+// Codec is the encoding of the offsets in the encoded file.
+type Codec uint8
+
+const (
+	// CodecFixed encodes each offset to Width bytes by Encoder.
+	CodecFixed Codec = iota + 1
+	// CodecVarint encodes each offset to variable-length bytes by VarintEncoder.
+	CodecVarint
+	// CodecBits encodes each offset to Width bits by BitEncoder.
+	CodecBits
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecFixed:
+		return "fixed"
+	case CodecVarint:
+		return "varint"
+	case CodecBits:
+		return "bits"
+	default:
+		return fmt.Sprintf("Codec(%d)", uint8(c))
+	}
+}
+
+// Header is the header of the encoded file.
+type Header struct {
+	Codec Codec
+	Width int   // the byte length of CodecFixed, the bit length of CodecBits or 0 for CodecVarint
+	Count int64 // the number of offsets or -1 when unknown
+
+	VocabSize     int64  // the total bytes of the vocabulary file
+	VocabChecksum uint32 // CRC-32C of the vocabulary file
+}
+
+// validate returns an error when h has an invalid codec or width.
+func (h *Header) validate() error {
+	switch h.Codec {
+	case CodecFixed:
+		if h.Width < 1 || 8 < h.Width {
+			return fmt.Errorf("invalid byte length: %d", h.Width)
+		}
+	case CodecVarint:
+		if h.Width != 0 {
+			return fmt.Errorf("invalid width of varint: %d", h.Width)
+		}
+	case CodecBits:
+		if h.Width < 1 || 63 < h.Width {
+			return fmt.Errorf("invalid bit length: %d", h.Width)
+		}
+	default:
+		return fmt.Errorf("unknown codec: %v", h.Codec)
+	}
+	return nil
+}
+
+// marshal returns the bytes of h.
+func (h *Header) marshal() []byte {
+	b := make([]byte, HeaderSize)
+	copy(b, formatMagic)
+	binary.BigEndian.PutUint16(b[4:], formatVersion)
+	b[6] = byte(h.Codec)
+	b[7] = byte(h.Width)
+	binary.BigEndian.PutUint64(b[8:], uint64(h.Count))
+	binary.BigEndian.PutUint64(b[16:], uint64(h.VocabSize))
+	binary.BigEndian.PutUint32(b[24:], h.VocabChecksum)
+	binary.BigEndian.PutUint32(b[28:], crc32.Checksum(b[:28], crc32cTable))
+	return b
+}
+
+// ReadHeader reads the header of the encoded file from r.
+func ReadHeader(r io.Reader) (*Header, error) {
+	b := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+	if string(b[:4]) != formatMagic {
+		return nil, errors.New("invalid header: bad magic number")
+	}
+	if v := binary.BigEndian.Uint16(b[4:]); v != formatVersion {
+		return nil, fmt.Errorf("invalid header: unsupported version: %d", v)
+	}
+	if binary.BigEndian.Uint32(b[28:]) != crc32.Checksum(b[:28], crc32cTable) {
+		return nil, errors.New("invalid header: checksum mismatch")
+	}
+
+	h := &Header{
+		Codec:         Codec(b[6]),
+		Width:         int(b[7]),
+		Count:         int64(binary.BigEndian.Uint64(b[8:])),
+		VocabSize:     int64(binary.BigEndian.Uint64(b[16:])),
+		VocabChecksum: binary.BigEndian.Uint32(b[24:]),
+	}
+	if err := h.validate(); err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+	if h.Count < -1 || h.VocabSize < 0 {
+		return nil, fmt.Errorf("invalid header: %d offsets of %d bytes vocabulary", h.Count, h.VocabSize)
+	}
+	return h, nil
+}
+
+// Writer writes the encoded file which begins with the header.
+// The caller must call Close after the last Encode.
+type Writer struct {
+	w      io.Writer
+	bw     *bufio.Writer
+	header Header
+	enc    ByteEncoder // nil for CodecBits
+	bits   *BitEncoder
+	count  int64
+	start  int64 // the position of the header or -1 when w cannot seek
+}
+
+// NewWriter writes the header to w and returns a Writer which encodes offsets by codec.
+// The width is the byte length for CodecFixed, the bit length for CodecBits and ignored
+// for CodecVarint. The vocab is the vocabulary file of size bytes. Its checksum is recorded
+// in the header, so that NewReader can detect another vocabulary file.
+//
+// When w implements io.WriteSeeker, Close records the number of offsets in the header.
+// Otherwise the number is recorded as unknown, and Close of CodecBits writes the number of
+// the padding bits after the offsets.
+func NewWriter(w io.Writer, vocab io.ReaderAt, size int64, codec Codec, width int) (*Writer, error) {
+	if codec == CodecVarint {
+		width = 0
+	}
+	header := Header{Codec: codec, Width: width, Count: -1, VocabSize: size}
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+
+	h := crc32.New(crc32cTable)
+	if err := copyChecksum(h, vocab, size); err != nil {
+		return nil, err
+	}
+	header.VocabChecksum = h.Sum32()
+
+	fw := &Writer{w: w, bw: bufio.NewWriter(w), header: header, start: -1}
+	if ws, ok := w.(io.WriteSeeker); ok {
+		// a pipe implements io.WriteSeeker but cannot seek
+		if start, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			fw.start = start
+		}
+	}
+	switch codec {
+	case CodecFixed:
+		fw.enc = &Encoder{l: width}
+	case CodecVarint:
+		fw.enc = NewVarintEncoder()
+	case CodecBits:
+		fw.bits = &BitEncoder{w: fw.bw, bitLen: width, buf: make([]byte, 0, bitEncoderBufSize)}
+	}
+
+	if _, err := fw.bw.Write(header.marshal()); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+// Encode encodes offset and writes it.
+func (fw *Writer) Encode(offset int64) error {
+	var err error
+	if fw.bits != nil {
+		err = fw.bits.Encode(offset)
+	} else {
+		err = fw.enc.Encode(fw.bw, offset)
+	}
+	if err != nil {
+		return err
+	}
+	fw.count++
+	return nil
+}
+
+// EncodeString encodes s and writes it.
+func (fw *Writer) EncodeString(oe OffsetEncoder, s string) error {
+	offset, err := oe.OffsetEncode(s)
+	if err != nil {
+		return err
+	}
+	if err := fw.Encode(offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close flushes the buffered data. When the underlying writer implements io.WriteSeeker,
+// it rewrites the header with the number of offsets. It does not close the underlying writer.
+func (fw *Writer) Close() error {
+	if fw.bits != nil {
+		padding := (8 - fw.bits.n) % 8
+		if err := fw.bits.Flush(); err != nil {
+			return err
+		}
+		if fw.start < 0 {
+			if err := fw.bw.WriteByte(byte(padding)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := fw.bw.Flush(); err != nil {
+		return err
+	}
+
+	if fw.start < 0 {
+		return nil
+	}
+	ws := fw.w.(io.WriteSeeker)
+	end, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	fw.header.Count = fw.count
+	if _, err := ws.Seek(fw.start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := ws.Write(fw.header.marshal()); err != nil {
+		return err
+	}
+	_, err = ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// Reader reads the encoded file which Writer writes.
+// It detects the codec from the header.
+type Reader struct {
+	br     *bufio.Reader
+	header Header
+	dec    ByteDecoder // nil for CodecBits
+	bits   *BitDecoder
+	buf    []byte // the buffer of CodecFixed
+	n      int64  // the number of decoded offsets
+}
+
+// NewReader reads the header from r and returns a Reader. The vocab is the vocabulary file
+// of size bytes. When it is not the one which the file was written with,
+// NewReader returns ErrVocabMismatch.
+func NewReader(r io.Reader, vocab io.ReaderAt, size int64) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := ReadHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if header.VocabSize != size {
+		return nil, ErrVocabMismatch
+	}
+	h := crc32.New(crc32cTable)
+	if err := copyChecksum(h, vocab, size); err != nil {
+		return nil, err
+	}
+	if h.Sum32() != header.VocabChecksum {
+		return nil, ErrVocabMismatch
+	}
+
+	fr := &Reader{br: br, header: *header}
+	switch header.Codec {
+	case CodecFixed:
+		fr.buf = make([]byte, 8)
+	case CodecVarint:
+		fr.dec = NewVarintDecoder()
+	case CodecBits:
+		fr.bits = &BitDecoder{r: br, bitLen: header.Width}
+	}
+	return fr, nil
+}
+
+// Header returns the header of the file.
+func (fr *Reader) Header() Header {
+	return fr.header
+}
+
+// Decode reads and decodes the next offset. It returns io.EOF at the end of the file.
+// When the number of offsets is recorded in the header, it returns io.EOF after that number
+// of offsets and io.ErrUnexpectedEOF when the file ends before it.
+func (fr *Reader) Decode() (offset int64, err error) {
+	if fr.header.Count >= 0 && fr.n >= fr.header.Count {
+		return 0, io.EOF
+	}
+
+	switch {
+	case fr.bits != nil:
+		offset, err = fr.decodeBits()
+	case fr.dec != nil:
+		offset, err = fr.dec.Decode(fr.br)
+	default:
+		offset, err = fr.decodeFixed()
+	}
+	if err == io.EOF && fr.header.Count >= 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	fr.n++
+	return offset, nil
+}
+
+// decodeBits decodes the next offset of CodecBits. When the count is unknown, it stops
+// at the padding which the last byte of the file tells.
+func (fr *Reader) decodeBits() (int64, error) {
+	if fr.header.Count >= 0 {
+		return fr.bits.Decode()
+	}
+
+	if b, err := fr.br.Peek(2); len(b) < 2 {
+		if err != io.EOF {
+			return 0, err
+		}
+		// the file must end with the number of the padding bits
+		if len(b) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		// only the unread bits of the current byte are left
+		switch rest := fr.bits.n - int(b[0]); {
+		case rest == 0:
+			return 0, io.EOF
+		case rest < fr.bits.bitLen:
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	return fr.bits.Decode()
+}
+
+// decodeFixed decodes the next offset of CodecFixed.
+func (fr *Reader) decodeFixed() (int64, error) {
+	l := fr.header.Width
+	if _, err := io.ReadFull(fr.br, fr.buf[8-l:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(fr.buf)), nil
+}
+
+// DecodeString reads and decodes the next string.
+func (fr *Reader) DecodeString(od OffsetDecoder) (s string, err error) {
+	offset, err := fr.Decode()
+	if err != nil {
+		return
+	}
+	s, err = od.OffsetDecode(offset)
+	if err != nil {
+		return
+	}
+	return
+}
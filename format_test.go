@@ -0,0 +1,390 @@
+package nwenc
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriter_Reader(t *testing.T) {
+	vocab := strings.NewReader("a\nbcd\nefg\nhijk\n")
+	words := []string{"bcd", "a", "hijk", "efg", "a"}
+
+	tests := []struct {
+		codec Codec
+		width int
+	}{
+		{CodecFixed, 1},
+		{CodecFixed, 8},
+		{CodecVarint, 0},
+		{CodecVarint, 5},
+		{CodecBits, 4},
+		{CodecBits, 63},
+	}
+
+	om, err := NewAllReadOffsetMapper(vocab)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for idx, test := range tests {
+		buf := new(bytes.Buffer)
+		w, err := NewWriter(buf, vocab, vocab.Size(), test.codec, test.width)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		for _, word := range words {
+			if err := w.EncodeString(om, word); err != nil {
+				t.Errorf("[%d] unexpected error: %v", idx, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+		}
+
+		r, err := NewReader(buf, vocab, vocab.Size())
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		if h := r.Header(); h.Codec != test.codec || h.Count != -1 {
+			t.Errorf("[%d] unexpected header: %+v", idx, h)
+		}
+
+		var out []string
+		for {
+			s, err := r.DecodeString(om)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("[%d] unexpected error: %v", idx, err)
+				break
+			}
+			out = append(out, s)
+		}
+		if !reflect.DeepEqual(words, out) {
+			t.Errorf("[%d] expected %v, but got %v", idx, words, out)
+		}
+	}
+}
+
+func TestWriter_Close(t *testing.T) {
+	words := "a\nbcd\nefg\nhijk\n"
+	vocab := strings.NewReader(words)
+	offsets := []int64{2, 0, 10, 6, 0}
+
+	f, err := ioutil.TempFile("", "nwenc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// the header does not need to be at the beginning of the file
+	if _, err := f.WriteString("prefix"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w, err := NewWriter(f, vocab, vocab.Size(), CodecBits, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, offset := range offsets {
+		if err := w.Encode(offset); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pos, _ := f.Seek(0, io.SeekCurrent); pos != 6+HeaderSize+3 {
+		t.Errorf("expected %d, but got %d", 6+HeaderSize+3, pos)
+	}
+
+	if _, err := f.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := NewReader(f, vocab, vocab.Size())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := Header{
+		Codec:         CodecBits,
+		Width:         4,
+		Count:         5,
+		VocabSize:     vocab.Size(),
+		VocabChecksum: crc32.Checksum([]byte(words), crc32cTable),
+	}
+	if h := r.Header(); h != expected {
+		t.Errorf("expected %+v, but got %+v", expected, h)
+	}
+
+	// the padding is not decoded because the count is known
+	var out []int64
+	for {
+		offset, err := r.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out = append(out, offset)
+	}
+	if !reflect.DeepEqual(offsets, out) {
+		t.Errorf("expected %v, but got %v", offsets, out)
+	}
+}
+
+func TestReader_Decode_Padding(t *testing.T) {
+	words := "a\nbcd\nefg\nhijk\n"
+	vocab := strings.NewReader(words)
+
+	tests := []struct {
+		width   int
+		offsets []int64
+		trim    int // the bytes cut from the end of the file
+		out     []int64
+		err     error
+	}{
+		{2, []int64{2}, 0, []int64{2}, io.EOF},
+		{2, []int64{2, 0, 1}, 0, []int64{2, 0, 1}, io.EOF},
+		{3, []int64{2, 0, 6, 6, 1}, 0, []int64{2, 0, 6, 6, 1}, io.EOF},
+		{4, []int64{}, 0, nil, io.EOF},
+		{4, []int64{10, 0}, 0, []int64{10, 0}, io.EOF},
+		{12, []int64{10, 0}, 0, []int64{10, 0}, io.EOF},
+		{2, []int64{2}, 1, nil, io.ErrUnexpectedEOF},
+		{4, []int64{}, 1, nil, io.ErrUnexpectedEOF},
+		{12, []int64{10, 0}, 2, []int64{10}, io.ErrUnexpectedEOF},
+	}
+
+	for idx, test := range tests {
+		buf := new(bytes.Buffer)
+		w, err := NewWriter(buf, vocab, vocab.Size(), CodecBits, test.width)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		for _, offset := range test.offsets {
+			if err := w.Encode(offset); err != nil {
+				t.Errorf("[%d] unexpected error: %v", idx, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+		}
+
+		data := buf.Bytes()[:buf.Len()-test.trim]
+		r, err := NewReader(bytes.NewReader(data), vocab, vocab.Size())
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+
+		var out []int64
+		for {
+			offset, err := r.Decode()
+			if err != nil {
+				if err != test.err {
+					t.Errorf("[%d] expected %v, but got %v", idx, test.err, err)
+				}
+				break
+			}
+			out = append(out, offset)
+		}
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, out)
+		}
+	}
+}
+
+func TestNewWriter_Invalid(t *testing.T) {
+	tests := []struct {
+		codec Codec
+		width int
+	}{
+		{CodecFixed, 0},
+		{CodecFixed, 9},
+		{CodecBits, 0},
+		{CodecBits, 64},
+		{Codec(0), 1},
+		{Codec(4), 1},
+	}
+
+	vocab := strings.NewReader("a\n")
+	for idx, test := range tests {
+		if _, err := NewWriter(new(bytes.Buffer), vocab, vocab.Size(), test.codec, test.width); err == nil {
+			t.Errorf("[%d] expected error, but got nil", idx)
+		}
+	}
+}
+
+func TestNewReader_Invalid(t *testing.T) {
+	vocab := strings.NewReader("a\nbcd\nefg\n")
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(buf, vocab, vocab.Size(), CodecFixed, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Encode(2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b := buf.Bytes()
+
+	// corrupt returns a copy of b whose i-th byte is flipped.
+	corrupt := func(i int) []byte {
+		c := append([]byte{}, b...)
+		c[i] ^= 0x01
+		return c
+	}
+
+	tests := []struct {
+		vocab string
+		data  []byte
+		err   string
+	}{
+		{
+			"a\nbcd\nefh\n",
+			b,
+			ErrVocabMismatch.Error(),
+		},
+		{
+			"a\nbcd\nefg\nh\n",
+			b,
+			ErrVocabMismatch.Error(),
+		},
+		{
+			"a\nbcd\nefg\n",
+			b[:HeaderSize-1],
+			"invalid header: unexpected EOF",
+		},
+		{
+			"a\nbcd\nefg\n",
+			corrupt(0),
+			"invalid header: bad magic number",
+		},
+		{
+			"a\nbcd\nefg\n",
+			corrupt(5),
+			"invalid header: unsupported version: 0",
+		},
+		{
+			"a\nbcd\nefg\n",
+			corrupt(6),
+			"invalid header: checksum mismatch",
+		},
+	}
+
+	for idx, test := range tests {
+		r := strings.NewReader(test.vocab)
+		_, err := NewReader(bytes.NewReader(test.data), r, r.Size())
+		if err == nil || err.Error() != test.err {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.err, err)
+		}
+	}
+}
+
+func TestReadHeader_Invalid(t *testing.T) {
+	tests := []struct {
+		in  Header
+		err string
+	}{
+		{
+			Header{Codec: CodecFixed, Width: 9, Count: -1},
+			"invalid header: invalid byte length: 9",
+		},
+		{
+			Header{Codec: CodecVarint, Width: 1, Count: -1},
+			"invalid header: invalid width of varint: 1",
+		},
+		{
+			Header{Codec: Codec(7), Width: 1, Count: -1},
+			"invalid header: unknown codec: Codec(7)",
+		},
+		{
+			Header{Codec: CodecFixed, Width: 1, Count: -2},
+			"invalid header: -2 offsets of 0 bytes vocabulary",
+		},
+	}
+
+	for idx, test := range tests {
+		_, err := ReadHeader(bytes.NewReader(test.in.marshal()))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.err, err)
+		}
+	}
+}
+
+func TestReader_Decode_Truncated(t *testing.T) {
+	words := "a\nbcd\nefg\n"
+	vocab := strings.NewReader(words)
+	h := Header{
+		Codec:         CodecFixed,
+		Width:         2,
+		Count:         2,
+		VocabSize:     vocab.Size(),
+		VocabChecksum: crc32.Checksum([]byte(words), crc32cTable),
+	}
+
+	tests := []struct {
+		data []byte
+		out  []int64
+		err  error
+	}{
+		{
+			[]byte{0, 2, 0, 6},
+			[]int64{2, 6},
+			io.EOF,
+		},
+		{
+			// the data after the count is ignored
+			[]byte{0, 2, 0, 6, 0, 0},
+			[]int64{2, 6},
+			io.EOF,
+		},
+		{
+			[]byte{0, 2, 0},
+			[]int64{2},
+			io.ErrUnexpectedEOF,
+		},
+		{
+			[]byte{0, 2},
+			[]int64{2},
+			io.ErrUnexpectedEOF,
+		},
+	}
+
+	for idx, test := range tests {
+		data := append(h.marshal(), test.data...)
+		r, err := NewReader(bytes.NewReader(data), vocab, vocab.Size())
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+
+		var out []int64
+		for {
+			offset, err := r.Decode()
+			if err != nil {
+				if err != test.err {
+					t.Errorf("[%d] expected %v, but got %v", idx, test.err, err)
+				}
+				break
+			}
+			out = append(out, offset)
+		}
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package nwenc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"unicode/utf8"
+)
+
+// The index file consists of the header, the offsets table and the trailer.
+// All integers are big endian.
+//
+//	magic     [4]byte  "NWIX"
+//	version   uint16   indexVersion
+//	reserved  uint16   0
+//	size      int64    the total bytes of the word file
+//	modTime   int64    the modification time of the word file in Unix nanoseconds, 0 if unknown
+//	checksum  uint32   CRC-32C of the word file
+//	count     int64    the number of lines
+//	offsets   [count]int64
+//	trailer   uint32   CRC-32C of all of the above
+const (
+	indexMagic      = "NWIX"
+	indexVersion    = 2
+	indexHeaderSize = 4 + 2 + 2 + 8 + 8 + 4 + 8
+)
+
+// crc32cTable is the table of CRC-32C (Castagnoli), which is hardware accelerated on most CPUs.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrIndexMismatch is returned when the index file was built from another word file.
+var ErrIndexMismatch = errors.New("index does not match the word file")
+
+// statter is a file which has its information, such as *os.File.
+type statter interface {
+	Stat() (os.FileInfo, error)
+}
+
+// modTimeOf returns the modification time of r in Unix nanoseconds, or 0 when it is unknown.
+func modTimeOf(r interface{}) (int64, error) {
+	s, ok := r.(statter)
+	if !ok {
+		return 0, nil
+	}
+	info, err := s.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.ModTime().UnixNano(), nil
+}
+
+// WriteIndex reads all of the word file r and writes its index file to w.
+// The index lets NewIndexOffsetMapper start without scanning the word file.
+// When r has the Stat method like *os.File, the modification time is also recorded.
+func WriteIndex(w io.Writer, r io.Reader) error {
+	modTime, err := modTimeOf(r)
+	if err != nil {
+		return err
+	}
+
+	h := crc32.New(crc32cTable)
+	var offsets []int64
+	var size int64
+	lineHead := true // whether the next byte begins a line
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		h.Write(buf[:n])
+		for i := 0; i < n; {
+			if lineHead {
+				offsets = append(offsets, size+int64(i))
+				lineHead = false
+			}
+			j := bytes.IndexByte(buf[i:n], '\n')
+			if j < 0 {
+				break
+			}
+			i += j + 1
+			lineHead = true
+		}
+		size += int64(n)
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeIndex(w, size, modTime, h.Sum32(), offsets)
+}
+
+// writeIndex writes the index file to w.
+func writeIndex(w io.Writer, size, modTime int64, checksum uint32, offsets []int64) error {
+	bw := bufio.NewWriter(w)
+	h := crc32.New(crc32cTable)
+	mw := io.MultiWriter(bw, h)
+
+	header := make([]byte, indexHeaderSize)
+	copy(header, indexMagic)
+	binary.BigEndian.PutUint16(header[4:], indexVersion)
+	binary.BigEndian.PutUint64(header[8:], uint64(size))
+	binary.BigEndian.PutUint64(header[16:], uint64(modTime))
+	binary.BigEndian.PutUint32(header[24:], checksum)
+	binary.BigEndian.PutUint64(header[28:], uint64(len(offsets)))
+	if _, err := mw.Write(header); err != nil {
+		return err
+	}
+
+	b := make([]byte, 8)
+	for _, offset := range offsets {
+		binary.BigEndian.PutUint64(b, uint64(offset))
+		if _, err := mw.Write(b); err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(b, h.Sum32())
+	if _, err := bw.Write(b[:4]); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// readIndex reads the index file from r and returns the size, the modification time and
+// the checksum of the word file and the offsets of the lines.
+func readIndex(r io.Reader) (size, modTime int64, checksum uint32, offsets []int64, err error) {
+	h := crc32.New(crc32cTable)
+	tr := io.TeeReader(bufio.NewReader(r), h)
+
+	header := make([]byte, indexHeaderSize)
+	if _, err = io.ReadFull(tr, header); err != nil {
+		err = fmt.Errorf("invalid index: %v", err)
+		return
+	}
+	if string(header[:4]) != indexMagic {
+		err = errors.New("invalid index: bad magic number")
+		return
+	}
+	if v := binary.BigEndian.Uint16(header[4:]); v != indexVersion {
+		err = fmt.Errorf("invalid index: unsupported version: %d", v)
+		return
+	}
+	size = int64(binary.BigEndian.Uint64(header[8:]))
+	modTime = int64(binary.BigEndian.Uint64(header[16:]))
+	checksum = binary.BigEndian.Uint32(header[24:])
+	count := int64(binary.BigEndian.Uint64(header[28:]))
+	if size < 0 || count < 0 || count > size {
+		err = fmt.Errorf("invalid index: %d lines in %d bytes", count, size)
+		return
+	}
+
+	offsets, err = readOffsets(tr, count, size)
+	if err != nil {
+		return
+	}
+
+	sum := h.Sum32()
+	trailer := make([]byte, 4)
+	if _, err = io.ReadFull(tr, trailer); err != nil {
+		err = fmt.Errorf("invalid index: %v", err)
+		return
+	}
+	if binary.BigEndian.Uint32(trailer) != sum {
+		err = errors.New("invalid index: checksum mismatch")
+		return
+	}
+	return
+}
+
+// readOffsets reads count offsets from r. The offsets must be ascending and less than size.
+func readOffsets(r io.Reader, count, size int64) (offsets []int64, err error) {
+	// a broken count must not allocate too much memory before the offsets are read
+	capacity := count
+	if capacity > 1<<20 {
+		capacity = 1 << 20
+	}
+	offsets = make([]int64, 0, capacity)
+	b := make([]byte, 8)
+	for i := int64(0); i < count; i++ {
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, fmt.Errorf("invalid index: %v", err)
+		}
+		offset := int64(binary.BigEndian.Uint64(b))
+		if offset < 0 || offset >= size || (i > 0 && offset <= offsets[i-1]) || (i == 0 && offset != 0) {
+			return nil, fmt.Errorf("invalid index: bad offset %d at %d", offset, i)
+		}
+		offsets = append(offsets, offset)
+	}
+	return
+}
+
+// IndexOffsetMapper is the implementation of OffsetMapper.
+// It loads the offsets of the lines from an index file which WriteIndex writes,
+// and reads each line by one io.ReaderAt call.
+// It is safe for concurrent use when r is.
+type IndexOffsetMapper struct {
+	r        io.ReaderAt
+	size     int64
+	checksum uint32
+	offsets  []int64
+}
+
+// NewIndexOffsetMapper returns an IndexOffsetMapper of r from the index file.
+// The size is the total bytes of r. It reads all of r to compare its checksum with the index,
+// and returns ErrIndexMismatch when r is not the word file which the index was built from.
+// Use NewIndexOffsetMapperUnverified to start without reading r.
+func NewIndexOffsetMapper(r io.ReaderAt, size int64, index io.Reader) (*IndexOffsetMapper, error) {
+	om, err := NewIndexOffsetMapperUnverified(r, size, index)
+	if err != nil {
+		return nil, err
+	}
+	if err := om.Verify(); err != nil {
+		return nil, err
+	}
+	return om, nil
+}
+
+// NewIndexOffsetMapperUnverified returns an IndexOffsetMapper of r from the index file
+// without reading r. It returns ErrIndexMismatch only when the size or the modification time
+// of r differs from the index. The modification time is compared only when r has the Stat
+// method like *os.File and the index records it.
+// It does not detect a word file which is rewritten in the same size with the modification
+// time kept, such as by cp -p. Call Verify to compare the checksum later.
+func NewIndexOffsetMapperUnverified(r io.ReaderAt, size int64, index io.Reader) (*IndexOffsetMapper, error) {
+	indexSize, indexModTime, checksum, offsets, err := readIndex(index)
+	if err != nil {
+		return nil, err
+	}
+	if indexSize != size {
+		return nil, ErrIndexMismatch
+	}
+
+	modTime, err := modTimeOf(r)
+	if err != nil {
+		return nil, err
+	}
+	if modTime != 0 && indexModTime != 0 && modTime != indexModTime {
+		return nil, ErrIndexMismatch
+	}
+
+	return &IndexOffsetMapper{r: r, size: size, checksum: checksum, offsets: offsets}, nil
+}
+
+// Verify reads all of the word file and returns ErrIndexMismatch when its checksum differs
+// from the index. It takes time in proportion to the size of the word file.
+func (om *IndexOffsetMapper) Verify() error {
+	h := crc32.New(crc32cTable)
+	if err := copyChecksum(h, om.r, om.size); err != nil {
+		return err
+	}
+	if h.Sum32() != om.checksum {
+		return ErrIndexMismatch
+	}
+	return nil
+}
+
+// copyChecksum writes size bytes of r into h.
+func copyChecksum(h hash.Hash32, r io.ReaderAt, size int64) error {
+	n, err := io.Copy(h, io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// OffsetEncode is the implementation of OffsetEncoder.
+// It reads a line by io.ReaderAt for each step of the binary search.
+// When s is not found or is empty, it will return OffsetEncodeError.
+func (om *IndexOffsetMapper) OffsetEncode(s string) (offset int64, err error) {
+	if s == "" {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+
+	i, err := om.lowerBound(0, func(line []byte) bool { return string(line) >= s })
+	if err != nil {
+		return
+	}
+	if i == len(om.offsets) {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+
+	line, err := om.line(i)
+	if err != nil {
+		return
+	}
+	if string(line) != s {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+	return om.offsets[i], nil
+}
+
+// OffsetDecode is the implementation of OffsetDecoder. It reads a line by io.ReaderAt.
+// When offset is not the beginning of a line or points an empty line,
+// it will return OffsetDecodeError.
+func (om *IndexOffsetMapper) OffsetDecode(offset int64) (s string, err error) {
+	i := sort.Search(len(om.offsets), func(i int) bool { return om.offsets[i] >= offset })
+	if i == len(om.offsets) || om.offsets[i] != offset {
+		err = &OffsetDecodeError{offset: offset}
+		return
+	}
+
+	line, err := om.line(i)
+	if err != nil {
+		return
+	}
+	if len(line) == 0 || !utf8.Valid(line) {
+		err = &OffsetDecodeError{offset: offset}
+		return
+	}
+
+	s = string(line)
+	return
+}
+
+// PrefixSearch is the implementation of PrefixSearcher.
+// When no string starts with prefix, it will return OffsetEncodeError.
+func (om *IndexOffsetMapper) PrefixSearch(prefix string) (first, last, n int64, err error) {
+	// the empty line, which is not a string, can be only the first line of the sorted file
+	i, err := om.lowerBound(0, func(line []byte) bool { return len(line) > 0 && string(line) >= prefix })
+	if err != nil {
+		return
+	}
+	j, err := om.lowerBound(i, func(line []byte) bool { return !bytes.HasPrefix(line, []byte(prefix)) })
+	if err != nil {
+		return
+	}
+	if i == j {
+		err = &OffsetEncodeError{s: prefix}
+		return
+	}
+
+	return om.offsets[i], om.offsets[j-1], int64(j - i), nil
+}
+
+// lowerBound returns the index of the first line from the i-th line which pred returns true.
+func (om *IndexOffsetMapper) lowerBound(i int, pred func(line []byte) bool) (j int, err error) {
+	j = i + sort.Search(len(om.offsets)-i, func(k int) bool {
+		if err != nil {
+			return true
+		}
+		var line []byte
+		line, err = om.line(i + k)
+		return err == nil && pred(line)
+	})
+	return
+}
+
+// line reads the i-th line ('\n' is not included).
+func (om *IndexOffsetMapper) line(i int) ([]byte, error) {
+	begin, end := om.offsets[i], om.size
+	if i+1 < len(om.offsets) {
+		end = om.offsets[i+1]
+	}
+
+	b := make([]byte, end-begin)
+	if n, err := om.r.ReadAt(b, begin); n < len(b) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return bytes.TrimSuffix(b, []byte{'\n'}), nil
+}
@@ -0,0 +1,494 @@
+package nwenc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestIndexOffsetMapper returns an IndexOffsetMapper of testdata/words.txt.
+// The caller must close the returned file.
+func newTestIndexOffsetMapper(t testing.TB) (*IndexOffsetMapper, *os.File) {
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	index := new(bytes.Buffer)
+	if err := WriteIndex(index, f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	om, err := NewIndexOffsetMapper(f, info.Size(), index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return om, f
+}
+
+func TestWriteIndex(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []int64
+	}{
+		{
+			"",
+			nil,
+		},
+		{
+			"a\n",
+			[]int64{0},
+		},
+		{
+			"a\nbcd\nefg\nhijk\n",
+			[]int64{0, 2, 6, 10},
+		},
+		{
+			"a\nbcd\nefg\nhijk",
+			[]int64{0, 2, 6, 10},
+		},
+		{
+			"a\n\nb\n",
+			[]int64{0, 2, 3},
+		},
+	}
+
+	for idx, test := range tests {
+		buf := new(bytes.Buffer)
+		if err := WriteIndex(buf, strings.NewReader(test.in)); err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+
+		size, _, _, offsets, err := readIndex(buf)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		if size != int64(len(test.in)) {
+			t.Errorf("[%d] size expected %d, but got %d", idx, len(test.in), size)
+		}
+		if len(test.out) != len(offsets) || (len(offsets) > 0 && !reflect.DeepEqual(test.out, offsets)) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, offsets)
+		}
+	}
+}
+
+func TestWriteIndex_Large(t *testing.T) {
+	// lines across the read buffer
+	words := make([]string, 20000)
+	for i := range words {
+		words[i] = strings.Repeat("a", i%7+1) + string(rune('a'+i%26))
+	}
+	text := strings.Join(words, "\n") + "\n"
+
+	buf := new(bytes.Buffer)
+	if err := WriteIndex(buf, strings.NewReader(text)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, _, _, offsets, err := readIndex(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	om, err := NewAllReadOffsetMapper(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(om.offsets, offsets) {
+		t.Errorf("offsets differ from NewAllReadOffsetMapper")
+	}
+}
+
+func TestNewIndexOffsetMapper_Invalid(t *testing.T) {
+	words := "a\nbcd\nefg\n"
+	index := new(bytes.Buffer)
+	if err := WriteIndex(index, strings.NewReader(words)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b := index.Bytes()
+
+	// corrupt returns a copy of b whose i-th byte is flipped.
+	corrupt := func(i int) []byte {
+		c := append([]byte{}, b...)
+		c[i] ^= 0x01
+		return c
+	}
+
+	tests := []struct {
+		words string
+		index []byte
+		err   string
+	}{
+		{
+			"a\nbcd\nefg\nh\n",
+			b,
+			ErrIndexMismatch.Error(),
+		},
+		{
+			words,
+			b[:len(b)-1],
+			"invalid index: unexpected EOF",
+		},
+		{
+			words,
+			nil,
+			"invalid index: EOF",
+		},
+		{
+			words,
+			corrupt(0),
+			"invalid index: bad magic number",
+		},
+		{
+			words,
+			corrupt(5),
+			"invalid index: unsupported version: 3",
+		},
+		{
+			words,
+			corrupt(indexHeaderSize + 8),
+			"invalid index: bad offset 72057594037927938 at 1",
+		},
+		{
+			words,
+			corrupt(len(b) - 1),
+			"invalid index: checksum mismatch",
+		},
+	}
+
+	for idx, test := range tests {
+		r := strings.NewReader(test.words)
+		_, err := NewIndexOffsetMapper(r, r.Size(), bytes.NewReader(test.index))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.err, err)
+		}
+	}
+
+	r := strings.NewReader("a\nbcd\nefg\nh\n")
+	if _, err := NewIndexOffsetMapper(r, r.Size(), bytes.NewReader(b)); !errors.Is(err, ErrIndexMismatch) {
+		t.Errorf("expected %v, but got %v", ErrIndexMismatch, err)
+	}
+}
+
+func TestIndexOffsetMapper_Verify(t *testing.T) {
+	words := "a\nbcd\nefg\n"
+	index := new(bytes.Buffer)
+	if err := WriteIndex(index, strings.NewReader(words)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		words string
+		err   error
+	}{
+		{words, nil},
+		{"a\nbcd\nefh\n", ErrIndexMismatch},
+	}
+
+	for idx, test := range tests {
+		r := &readCounter{r: strings.NewReader(test.words)}
+		if _, err := NewIndexOffsetMapper(r, int64(len(test.words)), bytes.NewReader(index.Bytes())); err != test.err {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.err, err)
+		}
+		if r.n != int64(len(test.words)) {
+			t.Errorf("[%d] expected %d bytes read, but got %d", idx, len(test.words), r.n)
+		}
+
+		r = &readCounter{r: strings.NewReader(test.words)}
+		om, err := NewIndexOffsetMapperUnverified(r, int64(len(test.words)), bytes.NewReader(index.Bytes()))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		if r.n != 0 {
+			t.Errorf("[%d] expected no read at startup, but read %d bytes", idx, r.n)
+		}
+		if err := om.Verify(); err != test.err {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.err, err)
+		}
+	}
+}
+
+// readCounter is an io.ReaderAt which counts the bytes read.
+type readCounter struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (rc *readCounter) ReadAt(p []byte, off int64) (int, error) {
+	n, err := rc.r.ReadAt(p, off)
+	rc.n += int64(n)
+	return n, err
+}
+
+func TestIndexOffsetMapper_ModTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nwenc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "words.txt")
+	if err := ioutil.WriteFile(path, []byte("a\nbcd\nefg\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	index := new(bytes.Buffer)
+	if err := WriteIndex(index, f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewIndexOffsetMapperUnverified(f, 10, bytes.NewReader(index.Bytes())); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the word file is rewritten in the same size
+	if err := ioutil.WriteFile(path, []byte("a\nbcd\nefh\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mtime := info.ModTime().Add(time.Hour)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewIndexOffsetMapperUnverified(f, 10, bytes.NewReader(index.Bytes())); err != ErrIndexMismatch {
+		t.Errorf("expected %v, but got %v", ErrIndexMismatch, err)
+	}
+
+	// the modification time is restored as cp -p does
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewIndexOffsetMapperUnverified(f, 10, bytes.NewReader(index.Bytes())); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewIndexOffsetMapper(f, 10, bytes.NewReader(index.Bytes())); err != ErrIndexMismatch {
+		t.Errorf("expected %v, but got %v", ErrIndexMismatch, err)
+	}
+}
+
+func TestIndexOffsetMapper_File(t *testing.T) {
+	// the index is saved to a file and loaded in another process in practice
+	dir, err := ioutil.TempDir("", "nwenc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	words := "a\nbcd\nefg\n"
+	indexPath := filepath.Join(dir, "words.idx")
+	indexFile, err := os.Create(indexPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := WriteIndex(indexFile, strings.NewReader(words)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	indexFile.Close()
+
+	indexFile, err = os.Open(indexPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer indexFile.Close()
+
+	r := strings.NewReader(words)
+	om, err := NewIndexOffsetMapper(r, r.Size(), indexFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if offset, err := om.OffsetEncode("efg"); offset != 6 || err != nil {
+		t.Errorf("expected (%d, %v), but got (%d, %v)", 6, nil, offset, err)
+	}
+}
+
+func TestIndexOffsetMapper_OffsetEncode(t *testing.T) {
+	type outType struct {
+		offset int64
+		err    error
+	}
+	tests := []struct {
+		in  string
+		out outType
+	}{
+		{
+			"a",
+			outType{0, nil},
+		},
+		{
+			"aaaabbbbccccddddeeeeffffgggghhhhiii",
+			outType{2, nil},
+		},
+		{
+			"abcd",
+			outType{38, nil},
+		},
+		{
+			"bcd",
+			outType{43, nil},
+		},
+		{
+			"defgh",
+			outType{47, nil},
+		},
+		{
+			"deg",
+			outType{53, nil},
+		},
+		{
+			"ijk",
+			outType{57, nil},
+		},
+		{
+			"ijkl",
+			outType{61, nil},
+		},
+		{
+			"",
+			outType{0, &OffsetEncodeError{s: ""}},
+		},
+		{
+			"0",
+			outType{0, &OffsetEncodeError{s: "0"}},
+		},
+		{
+			"aaaaa",
+			outType{0, &OffsetEncodeError{s: "aaaaa"}},
+		},
+		{
+			"ijkk",
+			outType{0, &OffsetEncodeError{s: "ijkk"}},
+		},
+		{
+			"z",
+			outType{0, &OffsetEncodeError{s: "z"}},
+		},
+	}
+
+	om, f := newTestIndexOffsetMapper(t)
+	defer f.Close()
+
+	for idx, test := range tests {
+		offset, err := om.OffsetEncode(test.in)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if test.out.offset != offset {
+			t.Errorf("[%d] expected %d, but got %d", idx, test.out.offset, offset)
+		}
+	}
+}
+
+func TestIndexOffsetMapper_OffsetDecode(t *testing.T) {
+	type outType struct {
+		s   string
+		err error
+	}
+	tests := []struct {
+		in  int64
+		out outType
+	}{
+		{
+			0, outType{"a", nil},
+		},
+		{
+			2, outType{"aaaabbbbccccddddeeeeffffgggghhhhiii", nil},
+		},
+		{
+			38, outType{"abcd", nil},
+		},
+		{
+			61, outType{"ijkl", nil},
+		},
+		{
+			-1, outType{"", &OffsetDecodeError{offset: -1}},
+		},
+		{
+			1, outType{"", &OffsetDecodeError{offset: 1}},
+		},
+		{
+			37, outType{"", &OffsetDecodeError{offset: 37}},
+		},
+		{
+			65, outType{"", &OffsetDecodeError{offset: 65}},
+		},
+		{
+			66, outType{"", &OffsetDecodeError{offset: 66}},
+		},
+	}
+
+	om, f := newTestIndexOffsetMapper(t)
+	defer f.Close()
+
+	for idx, test := range tests {
+		s, err := om.OffsetDecode(test.in)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			continue
+		}
+
+		if test.out.s != s {
+			t.Errorf("[%d] expected %#v, but got %#v", idx, test.out.s, s)
+		}
+	}
+}
+
+func TestIndexOffsetMapper_PrefixSearch(t *testing.T) {
+	om, f := newTestIndexOffsetMapper(t)
+	defer f.Close()
+
+	testPrefixSearch(t, om)
+}
+
+func BenchmarkIndexOffsetMapper_OffsetEncode(b *testing.B) {
+	queries := []string{
+		"a",
+		"aaaabbbbccccddddeeeeffffgggghhhhiii",
+		"abcd",
+		"bcd",
+		"defgh",
+		"deg",
+		"ijk",
+		"ijkl",
+	}
+
+	om, f := newTestIndexOffsetMapper(b)
+	defer f.Close()
+
+	for i := 0; i < b.N; i++ {
+		om.OffsetEncode(queries[i%len(queries)])
+	}
+}
+
+func BenchmarkIndexOffsetMapper_OffsetDecode(b *testing.B) {
+	queries := []int64{0, 2, 38, 43, 47, 53, 57, 61}
+
+	om, f := newTestIndexOffsetMapper(b)
+	defer f.Close()
+
+	for i := 0; i < b.N; i++ {
+		om.OffsetDecode(queries[i%len(queries)])
+	}
+}
@@ -0,0 +1,94 @@
+package nwenc
+
+// Iterator iterates the strings of an OffsetDecoder in file order. It works on every
+// OffsetDecoder because the next string begins right after '\n' of the current string.
+// The iteration stops at the end bound or at the end of the file. When a line before them
+// cannot be decoded, such as an empty line, the iteration stops and Err returns the error.
+//
+// Iterator example:
+//
+//	it := NewIterator(om, size, 0, -1)
+//	for it.Next() {
+//		fmt.Println(it.Offset(), it.Text())
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle err
+//	}
+type Iterator struct {
+	od   OffsetDecoder
+	size int64  // the total bytes of the file
+	next int64  // the offset of the next string
+	end  int64  // the end offset, negative means no bound
+	endS string // the end string, empty means no bound
+
+	offset int64
+	s      string
+	err    error
+	done   bool
+}
+
+// NewIterator returns an Iterator from begin to end offsets. The size is the total bytes
+// of the file. The begin must be the offset where a string begins. The end is exclusive.
+// A negative end means the end of the file.
+func NewIterator(od OffsetDecoder, size, begin, end int64) *Iterator {
+	return &Iterator{od: od, size: size, next: begin, end: end}
+}
+
+// NewStringIterator returns an Iterator from begin to end strings. The size is the total bytes
+// of the file. The text file must be sorted. The begin must be found by om, and an empty begin means the beginning of the file.
+// The end is exclusive and need not be found by om. An empty end means the end of the file.
+// When begin is not found, it will return OffsetEncodeError.
+func NewStringIterator(om OffsetMapper, size int64, begin, end string) (*Iterator, error) {
+	var offset int64
+	if begin != "" {
+		var err error
+		offset, err = om.OffsetEncode(begin)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Iterator{od: om, size: size, next: offset, end: -1, endS: end}, nil
+}
+
+// Next advances the Iterator to the next string. It returns false when the iteration stops.
+func (it *Iterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if it.next >= it.size || (it.end >= 0 && it.next >= it.end) {
+		it.done = true
+		return false
+	}
+
+	s, err := it.od.OffsetDecode(it.next)
+	if err != nil {
+		it.done = true
+		it.err = err
+		return false
+	}
+	if it.endS != "" && s >= it.endS {
+		it.done = true
+		return false
+	}
+
+	it.offset, it.s = it.next, s
+	it.next += int64(len(s)) + 1 // 1 means '\n'
+	return true
+}
+
+// Offset returns the offset of the current string.
+func (it *Iterator) Offset() int64 {
+	return it.offset
+}
+
+// Text returns the current string.
+func (it *Iterator) Text() string {
+	return it.s
+}
+
+// Err returns the error which stopped the iteration. It returns nil at the end bound or
+// at the end of the file.
+func (it *Iterator) Err() error {
+	return it.err
+}
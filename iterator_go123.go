@@ -0,0 +1,22 @@
+//go:build go1.23
+// +build go1.23
+
+package nwenc
+
+import "iter"
+
+// All returns an iter.Seq2 which yields the offsets and strings of it.
+// The caller should check Err after the loop.
+//
+//	for offset, s := range it.All() {
+//		fmt.Println(offset, s)
+//	}
+func (it *Iterator) All() iter.Seq2[int64, string] {
+	return func(yield func(int64, string) bool) {
+		for it.Next() {
+			if !yield(it.Offset(), it.Text()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build go1.23
+// +build go1.23
+
+package nwenc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIterator_All(t *testing.T) {
+	oms, closeFn := openTestMappers(t)
+	defer closeFn()
+
+	for name, om := range oms {
+		out := []iteratorOut{}
+		it := NewIterator(om, testSize, 38, -1)
+		for offset, s := range it.All() {
+			out = append(out, iteratorOut{offset, s})
+			if len(out) == 3 {
+				break
+			}
+		}
+
+		expected := []iteratorOut{{38, "abcd"}, {43, "bcd"}, {47, "defgh"}}
+		if !reflect.DeepEqual(expected, out) {
+			t.Errorf("[%s] expected %v, but got %v", name, expected, out)
+		}
+		if it.Err() != nil {
+			t.Errorf("[%s] unexpected error: %v", name, it.Err())
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package nwenc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openTestMappers opens testdata/words.txt with every OffsetMapper.
+// The caller must call the returned function to close them.
+func openTestMappers(t *testing.T) (map[string]OffsetMapper, func()) {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	allRead, err := NewAllReadOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mmap, err := NewMmapOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sparse, err := NewSparseOffsetMapper(f, info.Size(), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	index, indexFile := newTestIndexOffsetMapper(t)
+
+	oms := map[string]OffsetMapper{
+		"AllRead":    allRead,
+		"Seek":       NewSeekOffsetMapper(f, info.Size()),
+		"CachedSeek": NewCachedSeekOffsetMapper(f, info.Size()),
+		"Mmap":       mmap,
+		"Sparse":     sparse,
+		"Index":      index,
+	}
+	return oms, func() {
+		indexFile.Close()
+		mmap.Close()
+		f.Close()
+	}
+}
+
+// testSize is the size of testdata/words.txt.
+const testSize = 66
+
+// iteratorOut is a string which Iterator yields.
+type iteratorOut struct {
+	offset int64
+	s      string
+}
+
+// collectIterator collects all the strings of it.
+func collectIterator(it *Iterator) []iteratorOut {
+	out := []iteratorOut{}
+	for it.Next() {
+		out = append(out, iteratorOut{it.Offset(), it.Text()})
+	}
+	return out
+}
+
+func TestIterator(t *testing.T) {
+	type inType struct {
+		begin, end int64
+	}
+	tests := []struct {
+		in  inType
+		out []iteratorOut
+	}{
+		{
+			inType{0, -1},
+			[]iteratorOut{
+				{0, "a"},
+				{2, "aaaabbbbccccddddeeeeffffgggghhhhiii"},
+				{38, "abcd"},
+				{43, "bcd"},
+				{47, "defgh"},
+				{53, "deg"},
+				{57, "ijk"},
+				{61, "ijkl"},
+			},
+		},
+		{
+			inType{38, 53},
+			[]iteratorOut{{38, "abcd"}, {43, "bcd"}, {47, "defgh"}},
+		},
+		{
+			inType{43, 44},
+			[]iteratorOut{{43, "bcd"}},
+		},
+		{
+			inType{57, 100},
+			[]iteratorOut{{57, "ijk"}, {61, "ijkl"}},
+		},
+		{
+			inType{43, 43},
+			[]iteratorOut{},
+		},
+		{
+			inType{66, -1},
+			[]iteratorOut{},
+		},
+	}
+
+	oms, closeFn := openTestMappers(t)
+	defer closeFn()
+
+	for name, om := range oms {
+		for idx, test := range tests {
+			it := NewIterator(om, testSize, test.in.begin, test.in.end)
+			out := collectIterator(it)
+			if it.Err() != nil {
+				t.Errorf("[%s %d] unexpected error: %v", name, idx, it.Err())
+			}
+			if !reflect.DeepEqual(test.out, out) {
+				t.Errorf("[%s %d] expected %v, but got %v", name, idx, test.out, out)
+			}
+			if it.Next() {
+				t.Errorf("[%s %d] Next must be false after the iteration", name, idx)
+			}
+		}
+	}
+}
+
+func TestStringIterator(t *testing.T) {
+	type inType struct {
+		begin, end string
+	}
+	type outType struct {
+		out []iteratorOut
+		err error
+	}
+	tests := []struct {
+		in  inType
+		out outType
+	}{
+		{
+			inType{"", "b"},
+			outType{
+				[]iteratorOut{{0, "a"}, {2, "aaaabbbbccccddddeeeeffffgggghhhhiii"}, {38, "abcd"}},
+				nil,
+			},
+		},
+		{
+			inType{"abcd", "deg"},
+			outType{[]iteratorOut{{38, "abcd"}, {43, "bcd"}, {47, "defgh"}}, nil},
+		},
+		{
+			inType{"ijk", ""},
+			outType{[]iteratorOut{{57, "ijk"}, {61, "ijkl"}}, nil},
+		},
+		{
+			inType{"ijk", "ijk"},
+			outType{[]iteratorOut{}, nil},
+		},
+		{
+			inType{"zz", ""},
+			outType{nil, &OffsetEncodeError{s: "zz"}},
+		},
+	}
+
+	oms, closeFn := openTestMappers(t)
+	defer closeFn()
+
+	for name, om := range oms {
+		for idx, test := range tests {
+			it, err := NewStringIterator(om, testSize, test.in.begin, test.in.end)
+			if !reflect.DeepEqual(test.out.err, err) {
+				t.Errorf("[%s %d] expected %v, but got %v", name, idx, test.out.err, err)
+				continue
+			}
+			if err != nil {
+				continue
+			}
+
+			out := collectIterator(it)
+			if !reflect.DeepEqual(test.out.out, out) {
+				t.Errorf("[%s %d] expected %v, but got %v", name, idx, test.out.out, out)
+			}
+		}
+	}
+}
+
+// errOffsetDecoder is an OffsetDecoder which always fails.
+type errOffsetDecoder struct {
+	err error
+}
+
+func (od errOffsetDecoder) OffsetDecode(offset int64) (string, error) {
+	return "", od.err
+}
+
+func TestIterator_Err(t *testing.T) {
+	errTest := errors.New("test")
+
+	it := NewIterator(errOffsetDecoder{errTest}, 1, 0, -1)
+	if it.Next() {
+		t.Errorf("Next expected false, but got true")
+	}
+	if it.Err() != errTest {
+		t.Errorf("expected %v, but got %v", errTest, it.Err())
+	}
+
+	// an offset before the end of the file must be decoded
+	errDecode := &OffsetDecodeError{offset: 0}
+	it = NewIterator(errOffsetDecoder{errDecode}, 1, 0, -1)
+	if it.Next() {
+		t.Errorf("Next expected false, but got true")
+	}
+	if it.Err() != errDecode {
+		t.Errorf("expected %v, but got %v", errDecode, it.Err())
+	}
+
+	// the end of the file is not decoded
+	it = NewIterator(errOffsetDecoder{errTest}, 1, 1, -1)
+	if it.Next() {
+		t.Errorf("Next expected false, but got true")
+	}
+	if it.Err() != nil {
+		t.Errorf("unexpected error: %v", it.Err())
+	}
+}
+
+func TestIterator_EmptyLine(t *testing.T) {
+	words := "a\n\nb\nc\n"
+	r := strings.NewReader(words)
+	size := r.Size()
+
+	allRead, err := NewAllReadOffsetMapper(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sparse, err := NewSparseOffsetMapper(r, size, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	index := new(bytes.Buffer)
+	if err := WriteIndex(index, strings.NewReader(words)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	indexOM, err := NewIndexOffsetMapper(r, size, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ods := map[string]OffsetDecoder{
+		"AllRead":    allRead,
+		"Seek":       NewSeekOffsetMapper(r, size),
+		"CachedSeek": NewCachedSeekOffsetMapper(r, size),
+		"Mmap":       &MmapOffsetMapper{b: []byte(words)},
+		"Sparse":     sparse,
+		"Index":      indexOM,
+	}
+
+	for name, od := range ods {
+		it := NewIterator(od, size, 0, -1)
+		out := collectIterator(it)
+		if expected := []iteratorOut{{0, "a"}}; !reflect.DeepEqual(expected, out) {
+			t.Errorf("[%s] expected %v, but got %v", name, expected, out)
+		}
+		if expected := (&OffsetDecodeError{offset: 2}); !reflect.DeepEqual(expected, it.Err()) {
+			t.Errorf("[%s] expected %v, but got %v", name, expected, it.Err())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package nwenc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// KeySearcher searches the records of a sorted encoded file by their leading offsets.
+// Each record is recordLen bytes and begins with the offsets which Encoder encodes to
+// byteLen bytes, such as an n-gram of RecordCodec. Because the vocabulary file is sorted,
+// the order of the offsets is the order of the words, so a file sorted by the words
+// is sorted by the encoded bytes.
+// It is safe for concurrent use when r is.
+type KeySearcher struct {
+	r         io.ReaderAt
+	enc       *Encoder
+	recordLen int
+	n         int64 // the number of records
+}
+
+// NewKeySearcher returns a KeySearcher of r, whose size is size bytes.
+// The byteLen must be 1 <= byteLen <= 8 and the recordLen must be byteLen <= recordLen.
+// The size must be a multiple of the recordLen.
+func NewKeySearcher(r io.ReaderAt, size int64, byteLen, recordLen int) (*KeySearcher, error) {
+	enc, err := NewEncoder(byteLen)
+	if err != nil {
+		return nil, err
+	}
+	if recordLen < byteLen {
+		return nil, fmt.Errorf("invalid record length: %d", recordLen)
+	}
+	if size < 0 || size%int64(recordLen) != 0 {
+		return nil, fmt.Errorf("size is not a multiple of record length %d: %d", recordLen, size)
+	}
+	return &KeySearcher{r: r, enc: enc, recordLen: recordLen, n: size / int64(recordLen)}, nil
+}
+
+// Len returns the number of records.
+func (ks *KeySearcher) Len() int64 {
+	return ks.n
+}
+
+// Search returns the range [begin, end) of the indices of the records which begin with
+// the offsets. When no record matches, begin == end is where the records would be.
+// No offsets match all records.
+func (ks *KeySearcher) Search(offsets ...int64) (begin, end int64, err error) {
+	if len(offsets)*ks.enc.l > ks.recordLen {
+		err = fmt.Errorf("key is longer than the record: %d offsets", len(offsets))
+		return
+	}
+	key, err := ks.enc.EncodeSlice(nil, offsets)
+	if err != nil {
+		return
+	}
+	return ks.searchKey(key)
+}
+
+// SearchStrings returns the range [begin, end) of the indices of the records which begin with
+// the words in the same way as Search. The oe encodes the words. When a word is not found,
+// it will return OffsetEncodeError.
+func (ks *KeySearcher) SearchStrings(oe OffsetEncoder, words ...string) (begin, end int64, err error) {
+	offsets := make([]int64, len(words))
+	for i, word := range words {
+		if offsets[i], err = oe.OffsetEncode(word); err != nil {
+			return
+		}
+	}
+	return ks.Search(offsets...)
+}
+
+// searchKey returns the range of the records which begin with key.
+func (ks *KeySearcher) searchKey(key []byte) (begin, end int64, err error) {
+	b := make([]byte, len(key))
+
+	// compare returns the comparison of the i-th record and key.
+	compare := func(i int64) int {
+		if err != nil {
+			return 0
+		}
+		n, rerr := ks.r.ReadAt(b, i*int64(ks.recordLen))
+		if n < len(b) {
+			if rerr == nil || rerr == io.EOF {
+				rerr = io.ErrUnexpectedEOF
+			}
+			err = rerr
+			return 0
+		}
+		return bytes.Compare(b, key)
+	}
+
+	begin = ks.lowerBound(0, func(i int64) bool { return compare(i) >= 0 })
+	end = ks.lowerBound(begin, func(i int64) bool { return compare(i) > 0 })
+	if err != nil {
+		return 0, 0, err
+	}
+	return
+}
+
+// lowerBound returns the first index from i which pred returns true.
+func (ks *KeySearcher) lowerBound(i int64, pred func(i int64) bool) int64 {
+	// sort.Search cannot search more than max int records on 32-bit platforms
+	left, right := i, ks.n
+	for left < right {
+		mid := left + (right-left)/2
+		if pred(mid) {
+			right = mid
+		} else {
+			left = mid + 1
+		}
+	}
+	return left
+}
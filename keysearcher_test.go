@@ -0,0 +1,163 @@
+package nwenc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testBigram struct {
+	W1    string `nwenc:"word,1"`
+	W2    string `nwenc:"word,1"`
+	Count uint16 `nwenc:"uint,2"`
+}
+
+// newTestKeySearcher returns a KeySearcher of the sorted bigrams of testdata/words.txt.
+func newTestKeySearcher(t *testing.T) (*KeySearcher, *AllReadOffsetMapper) {
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	om, err := NewAllReadOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rc, err := NewRecordCodec(testBigram{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bigrams := []testBigram{
+		{"a", "a", 1},
+		{"a", "bcd", 2},
+		{"a", "ijk", 3},
+		{"abcd", "a", 4},
+		{"bcd", "a", 5},
+		{"bcd", "a", 6},
+		{"bcd", "deg", 7},
+		{"bcd", "ijkl", 8},
+		{"ijkl", "a", 9},
+	}
+	buf := new(bytes.Buffer)
+	for _, bigram := range bigrams {
+		if err := rc.Encode(buf, om, bigram); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	ks, err := NewKeySearcher(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 1, rc.Size())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return ks, om
+}
+
+func TestNewKeySearcher(t *testing.T) {
+	tests := []struct {
+		size      int64
+		byteLen   int
+		recordLen int
+		ok        bool
+	}{
+		{12, 3, 6, true},
+		{0, 3, 3, true},
+		{12, 3, 5, false},
+		{12, 3, 2, false},
+		{12, 0, 6, false},
+		{-6, 3, 6, false},
+	}
+
+	for idx, test := range tests {
+		_, err := NewKeySearcher(bytes.NewReader(nil), test.size, test.byteLen, test.recordLen)
+		if (err == nil) != test.ok {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+		}
+	}
+}
+
+func TestKeySearcher_SearchStrings(t *testing.T) {
+	ks, om := newTestKeySearcher(t)
+
+	type outType struct {
+		begin, end int64
+	}
+	tests := []struct {
+		in  []string
+		out outType
+	}{
+		{[]string{}, outType{0, 9}},
+		{[]string{"a"}, outType{0, 3}},
+		{[]string{"bcd"}, outType{4, 8}},
+		{[]string{"ijkl"}, outType{8, 9}},
+		{[]string{"bcd", "a"}, outType{4, 6}},
+		{[]string{"bcd", "ijkl"}, outType{7, 8}},
+		{[]string{"a", "a"}, outType{0, 1}},
+		{[]string{"aaaabbbbccccddddeeeeffffgggghhhhiii"}, outType{3, 3}},
+		{[]string{"bcd", "bcd"}, outType{6, 6}},
+		{[]string{"ijk"}, outType{8, 8}},
+		{[]string{"ijkl", "ijkl"}, outType{9, 9}},
+	}
+
+	for idx, test := range tests {
+		begin, end, err := ks.SearchStrings(om, test.in...)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		if test.out != (outType{begin, end}) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, outType{begin, end})
+		}
+	}
+
+	if _, _, err := ks.SearchStrings(om, "zzz"); !errors.As(err, new(*OffsetEncodeError)) {
+		t.Errorf("expected OffsetEncodeError, but got %v", err)
+	}
+}
+
+func TestKeySearcher_Search(t *testing.T) {
+	ks, _ := newTestKeySearcher(t)
+
+	type outType struct {
+		begin, end int64
+		err        bool
+	}
+	tests := []struct {
+		in  []int64
+		out outType
+	}{
+		{[]int64{43}, outType{4, 8, false}},
+		{[]int64{43, 0}, outType{4, 6, false}},
+		{[]int64{43, 0, 0}, outType{4, 6, false}},
+		{[]int64{43, 0, 0, 6}, outType{5, 6, false}},
+		// the offset need not be in the vocabulary
+		{[]int64{44}, outType{8, 8, false}},
+		{[]int64{43, 0, 0, 6, 0}, outType{0, 0, true}},
+		{[]int64{256}, outType{0, 0, true}},
+		{[]int64{-1}, outType{0, 0, true}},
+	}
+
+	for idx, test := range tests {
+		begin, end, err := ks.Search(test.in...)
+		if (err != nil) != test.out.err {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		if test.out != (outType{begin, end, err != nil}) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, outType{begin, end, err != nil})
+		}
+	}
+}
+
+func TestKeySearcher_ReadError(t *testing.T) {
+	// the size is larger than the file
+	ks, err := NewKeySearcher(bytes.NewReader([]byte{0, 1}), 4, 1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := ks.Search(2); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+}
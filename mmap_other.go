@@ -0,0 +1,23 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package nwenc
+
+import (
+	"io"
+	"os"
+)
+
+// mmap reads size bytes of f into memory because mmap is not supported on this platform.
+func mmap(f *os.File, size int64) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := f.ReadAt(b, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b, nil
+}
+
+// munmap does nothing because b is allocated by mmap on the Go heap.
+func munmap(b []byte) error {
+	return nil
+}
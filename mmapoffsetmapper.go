@@ -0,0 +1,163 @@
+package nwenc
+
+import (
+	"bytes"
+	"os"
+	"unicode/utf8"
+)
+
+// MmapOffsetMapper is the implementation of OffsetMapper.
+// It maps the file into memory and searches it in the same way as SeekOffsetMapper.
+// It works faster than SeekOffsetMapper and uses less heap memory than AllReadOffsetMapper
+// because the mapped file is kept in the OS page cache.
+type MmapOffsetMapper struct {
+	b   []byte
+	lax bool // accepts the offsets in the middle of a line
+}
+
+// NewMmapOffsetMapper returns a MmapOffsetMapper which maps all of f into memory.
+// The caller must call Close when the MmapOffsetMapper is no longer used.
+func NewMmapOffsetMapper(f *os.File) (*MmapOffsetMapper, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := mmap(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	return &MmapOffsetMapper{b: b}, nil
+}
+
+// Close unmaps the file. The MmapOffsetMapper must not be used after Close.
+func (om *MmapOffsetMapper) Close() error {
+	b := om.b
+	om.b = nil
+	return munmap(b)
+}
+
+// SetStrict sets whether OffsetDecode accepts only the offsets at the beginning of a line
+// in the same way as SeekOffsetMapper.SetStrict. It is true by default.
+func (om *MmapOffsetMapper) SetStrict(strict bool) {
+	om.lax = !strict
+}
+
+// OffsetEncode is the implementation of OffsetEncoder.
+// When s is not found or is empty, it will return OffsetEncodeError.
+func (om *MmapOffsetMapper) OffsetEncode(s string) (offset int64, err error) {
+	if s == "" {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+
+	offset, ok := bytesBinSearch(om.b, s)
+	if !ok {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+	return
+}
+
+// OffsetDecode is the implementation of OffsetDecoder.
+// When offset is out of the file, is not the beginning of a line in the strict mode
+// or points an empty line, it will return OffsetDecodeError.
+func (om *MmapOffsetMapper) OffsetDecode(offset int64) (s string, err error) {
+	if offset < 0 || offset >= int64(len(om.b)) {
+		err = &OffsetDecodeError{offset: offset}
+		return
+	}
+	if !om.lax && offset > 0 && om.b[offset-1] != '\n' {
+		err = &OffsetDecodeError{offset: offset}
+		return
+	}
+
+	line := om.b[offset:bytesEndOfLine(om.b, int(offset))]
+	if len(line) == 0 || !utf8.Valid(line) {
+		err = &OffsetDecodeError{offset: offset}
+		return
+	}
+
+	s = string(line)
+	return
+}
+
+// PrefixSearch is the implementation of PrefixSearcher.
+// When no string starts with prefix, it will return OffsetEncodeError.
+func (om *MmapOffsetMapper) PrefixSearch(prefix string) (first, last, n int64, err error) {
+	// the empty line, which is not a string, can be only the first line of the sorted file
+	i := bytesLowerBound(om.b, 0, func(line []byte) bool {
+		return len(line) > 0 && string(line) >= prefix
+	})
+	j := bytesLowerBound(om.b, i, func(line []byte) bool {
+		return len(line) < len(prefix) || string(line[:len(prefix)]) != prefix
+	})
+	if i == j {
+		err = &OffsetEncodeError{s: prefix}
+		return
+	}
+
+	n = int64(bytes.Count(om.b[i:j], []byte{'\n'}))
+	if om.b[j-1] != '\n' {
+		n++
+	}
+	return int64(i), int64(bytesBeginOfLine(om.b, j-1)), n, nil
+}
+
+// bytesBinSearch searches s from the lines of b. When s is found, ok will be true.
+func bytesBinSearch(b []byte, s string) (offset int64, ok bool) {
+	left, right := 0, len(b)
+	for left < right {
+		begin := bytesBeginOfLine(b, left+(right-left)/2)
+		end := bytesEndOfLine(b, begin)
+
+		// string conversions in comparisons do not allocate.
+		if s == string(b[begin:end]) {
+			return int64(begin), true
+		} else if s < string(b[begin:end]) {
+			right = begin
+		} else {
+			left = end + 1
+		}
+	}
+
+	return
+}
+
+// bytesLowerBound returns the index of the first line in b after left which pred returns true.
+// The left must be the beginning of a line. The pred must return false for the lines before
+// it returns true. It returns len(b) when no line matches.
+func bytesLowerBound(b []byte, left int, pred func(line []byte) bool) int {
+	right := len(b)
+	for left < right {
+		begin := bytesBeginOfLine(b, left+(right-left)/2)
+		end := bytesEndOfLine(b, begin)
+
+		if pred(b[begin:end]) {
+			right = begin
+		} else if end < right {
+			left = end + 1
+		} else {
+			left = right
+		}
+	}
+
+	return left
+}
+
+// bytesBeginOfLine returns the beginning of the line which contains i.
+// When b[i] is '\n', it is treated as the end of the line.
+func bytesBeginOfLine(b []byte, i int) int {
+	return bytes.LastIndexByte(b[:i], '\n') + 1
+}
+
+// bytesEndOfLine returns the index of '\n' which ends the line beginning at i.
+// It returns len(b) when the line is not terminated by '\n'.
+func bytesEndOfLine(b []byte, i int) int {
+	n := bytes.IndexByte(b[i:], '\n')
+	if n < 0 {
+		return len(b)
+	}
+	return i + n
+}
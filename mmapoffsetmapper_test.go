@@ -0,0 +1,282 @@
+package nwenc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMmapOffsetMapper_OffsetEncode(t *testing.T) {
+	type outType struct {
+		offset int64
+		err    error
+	}
+	tests := []struct {
+		in  string
+		out outType
+	}{
+		{
+			"a",
+			outType{0, nil},
+		},
+		{
+			"aaaabbbbccccddddeeeeffffgggghhhhiii",
+			outType{2, nil},
+		},
+		{
+			"abcd",
+			outType{38, nil},
+		},
+		{
+			"bcd",
+			outType{43, nil},
+		},
+		{
+			"defgh",
+			outType{47, nil},
+		},
+		{
+			"deg",
+			outType{53, nil},
+		},
+		{
+			"ijk",
+			outType{57, nil},
+		},
+		{
+			"ijkl",
+			outType{61, nil},
+		},
+		{
+			"",
+			outType{0, &OffsetEncodeError{s: ""}},
+		},
+		{
+			"0",
+			outType{0, &OffsetEncodeError{s: "0"}},
+		},
+		{
+			"aaaaa",
+			outType{0, &OffsetEncodeError{s: "aaaaa"}},
+		},
+		{
+			"ijkk",
+			outType{0, &OffsetEncodeError{s: "ijkk"}},
+		},
+		{
+			"z",
+			outType{0, &OffsetEncodeError{s: "z"}},
+		},
+	}
+
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	om, err := NewMmapOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer om.Close()
+
+	for idx, test := range tests {
+		offset, err := om.OffsetEncode(test.in)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if test.out.offset != offset {
+			t.Errorf("[%d] expected %d, but got %d", idx, test.out.offset, offset)
+		}
+	}
+}
+
+func BenchmarkMmapOffsetMapper_OffsetEncode(b *testing.B) {
+	queries := []string{
+		"a",
+		"aaaabbbbccccddddeeeeffffgggghhhhiii",
+		"abcd",
+		"bcd",
+		"defgh",
+		"deg",
+		"ijk",
+		"ijkl",
+	}
+
+	// open file
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	om, err := NewMmapOffsetMapper(f)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	defer om.Close()
+
+	for i := 0; i < b.N; i++ {
+		om.OffsetEncode(queries[i%len(queries)])
+	}
+}
+
+func TestMmapOffsetMapper_OffsetDecode(t *testing.T) {
+	type outType struct {
+		s   string
+		err error
+	}
+	tests := []struct {
+		in  int64
+		out outType
+	}{
+		{
+			0, outType{"a", nil},
+		},
+		{
+			2, outType{"aaaabbbbccccddddeeeeffffgggghhhhiii", nil},
+		},
+		{
+			38, outType{"abcd", nil},
+		},
+		{
+			61, outType{"ijkl", nil},
+		},
+		{
+			-1, outType{"", &OffsetDecodeError{offset: -1}},
+		},
+		{
+			1, outType{"", &OffsetDecodeError{offset: 1}},
+		},
+		{
+			3, outType{"", &OffsetDecodeError{offset: 3}},
+		},
+		{
+			37, outType{"", &OffsetDecodeError{offset: 37}},
+		},
+		{
+			65, outType{"", &OffsetDecodeError{offset: 65}},
+		},
+		{
+			66, outType{"", &OffsetDecodeError{offset: 66}},
+		},
+	}
+
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	om, err := NewMmapOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer om.Close()
+
+	for idx, test := range tests {
+		s, err := om.OffsetDecode(test.in)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			continue
+		}
+
+		if test.out.s != s {
+			t.Errorf("[%d] expected %#v, but got %#v", idx, test.out.s, s)
+		}
+	}
+}
+
+func TestMmapOffsetMapper_SetStrict(t *testing.T) {
+	om := &MmapOffsetMapper{b: []byte("a\nbcd\nefg")}
+	om.SetStrict(false)
+
+	if s, err := om.OffsetDecode(4); s != "d" || err != nil {
+		t.Errorf("expected %q, but got %q, %v", "d", s, err)
+	}
+	if _, err := om.OffsetDecode(1); !reflect.DeepEqual(&OffsetDecodeError{offset: 1}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetDecodeError{offset: 1}, err)
+	}
+
+	om.SetStrict(true)
+	if _, err := om.OffsetDecode(4); !reflect.DeepEqual(&OffsetDecodeError{offset: 4}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetDecodeError{offset: 4}, err)
+	}
+}
+
+func TestMmapOffsetMapper_PrefixSearch(t *testing.T) {
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	om, err := NewMmapOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer om.Close()
+
+	testPrefixSearch(t, om)
+
+	// the last line does not end with '\n'
+	om = &MmapOffsetMapper{b: []byte("ab\nac\nbb\nbcd")}
+	first, last, n, err := om.PrefixSearch("b")
+	out := prefixSearchOut{first, last, n, err}
+	if expected := (prefixSearchOut{6, 9, 2, nil}); expected != out {
+		t.Errorf("expected %v, but got %v", expected, out)
+	}
+}
+
+func TestMmapOffsetMapper_Empty(t *testing.T) {
+	f, err := ioutil.TempFile("", "nwenc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	om, err := NewMmapOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer om.Close()
+
+	if _, err := om.OffsetEncode("a"); !reflect.DeepEqual(&OffsetEncodeError{s: "a"}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetEncodeError{s: "a"}, err)
+	}
+	if _, err := om.OffsetDecode(0); !reflect.DeepEqual(&OffsetDecodeError{offset: 0}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetDecodeError{offset: 0}, err)
+	}
+}
+
+func BenchmarkMmapOffsetMapper_OffsetDecode(b *testing.B) {
+	queries := []int64{0, 2, 38, 43, 47, 53, 57, 61}
+
+	// open file
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	om, err := NewMmapOffsetMapper(f)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	defer om.Close()
+
+	for i := 0; i < b.N; i++ {
+		om.OffsetDecode(queries[i%len(queries)])
+	}
+}
@@ -0,0 +1,270 @@
+// Package nwenctest provides the conformance tests of the implementations of nwenc.OffsetMapper.
+//
+// An implementation is tested by a factory which builds it from a vocabulary:
+//
+//	func TestMyOffsetMapper(t *testing.T) {
+//		nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+//			return NewMyOffsetMapper(bytes.NewReader(vocab), int64(len(vocab)))
+//		})
+//	}
+package nwenctest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/high-moctane/nwenc"
+)
+
+// vocabTest is an edge-case vocabulary.
+type vocabTest struct {
+	name  string
+	vocab string
+}
+
+// vocabTests are the edge-case vocabularies of TestOffsetMapper.
+var vocabTests = []vocabTest{
+	{"empty", ""},
+	{"only empty line", "\n"},
+	{"empty first line", "\na\nab\nb\n"},
+	{"one word", "a\n"},
+	{"one word without newline", "abc"},
+	{"one byte words", "a\nb\nc\nx\ny\nz\n"},
+	{"without last newline", "a\nbcd\nefg"},
+	{"prefixes", "a\nab\nabc\nabcd\nb\nba\n"},
+	{"testdata", "a\naaaabbbbccccddddeeeeffffgggghhhhiii\nabcd\nbcd\ndefgh\ndeg\nijk\nijkl\n"},
+	{"long lines", lines(
+		strings.Repeat("a", 31),
+		strings.Repeat("a", 32),
+		strings.Repeat("a", 33),
+		strings.Repeat("b", 100),
+		strings.Repeat("c", 1000),
+		"d",
+	)},
+	{"spaces", lines("a\tb", "a b", "a", "b c", " ")},
+	{"multibyte", lines("é", "あ", "あい", "日本", "日本語", "z")},
+	{"many words", lines(manyWords(1000)...)},
+}
+
+// lines returns the vocabulary of words. The words are sorted in byte order.
+func lines(words ...string) string {
+	words = append([]string{}, words...)
+	sort.Strings(words)
+	return strings.Join(words, "\n") + "\n"
+}
+
+// manyWords returns n words of different lengths.
+func manyWords(n int) []string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", i*7)
+	}
+	return words
+}
+
+// vocabLine is a line of a vocabulary.
+type vocabLine struct {
+	s      string
+	offset int64
+}
+
+// parseVocab returns the lines of the words of vocab. The empty lines are not words.
+func parseVocab(vocab string) []vocabLine {
+	var ls []vocabLine
+	for offset := 0; offset < len(vocab); {
+		n := strings.IndexByte(vocab[offset:], '\n')
+		if n < 0 {
+			n = len(vocab) - offset
+		}
+		if n > 0 {
+			ls = append(ls, vocabLine{vocab[offset : offset+n], int64(offset)})
+		}
+		offset += n + 1
+	}
+	return ls
+}
+
+// TestOffsetMapper tests the OffsetMapper which factory returns for each edge-case vocabulary.
+// A vocabulary is a text file of words sorted in byte order, one per line, without
+// duplicates. The last line may not end with '\n'. An empty line is not a word, and
+// it can be only the first line of a sorted vocabulary.
+// The OffsetMapper must follow the rules:
+//
+//   - OffsetEncode returns the offset of the line of each word.
+//   - OffsetDecode returns the word of the offset of each line of a word.
+//   - OffsetEncode of a string which is not a word returns *nwenc.OffsetEncodeError.
+//     The empty string, a part of a line and a string with '\n' are not words.
+//   - OffsetDecode of an offset which is not the beginning of a line of a word returns
+//     *nwenc.OffsetDecodeError. The offsets of an empty line, in the middle of a line,
+//     of '\n', at or past the end of the file and negative are not.
+//   - When it implements nwenc.PrefixSearcher, PrefixSearch returns the offsets of the first
+//     and last words starting with the prefix and the number of the words. When no word
+//     starts with the prefix, it returns *nwenc.OffsetEncodeError.
+//
+// The factory must build the OffsetMapper from vocab or call t.Fatal. It is called for
+// each rule so that a cache of a result does not hide the others.
+// When the OffsetMapper implements io.Closer, it is closed after the test of the rule.
+func TestOffsetMapper(t *testing.T, factory func(t *testing.T, vocab []byte) nwenc.OffsetMapper) {
+	for _, vt := range vocabTests {
+		vt := vt
+		t.Run(vt.name, func(t *testing.T) {
+			ls := parseVocab(vt.vocab)
+			size := int64(len(vt.vocab))
+
+			// run runs test with a new OffsetMapper.
+			run := func(name string, test func(t *testing.T, om nwenc.OffsetMapper)) {
+				t.Run(name, func(t *testing.T) {
+					om := factory(t, []byte(vt.vocab))
+					if c, ok := om.(io.Closer); ok {
+						defer c.Close()
+					}
+					test(t, om)
+				})
+			}
+
+			run("OffsetEncode", func(t *testing.T, om nwenc.OffsetMapper) { testOffsetEncode(t, om, ls) })
+			run("OffsetDecode", func(t *testing.T, om nwenc.OffsetMapper) { testOffsetDecode(t, om, ls) })
+			run("Missing", func(t *testing.T, om nwenc.OffsetMapper) { testMissing(t, om, ls) })
+			run("InvalidOffset", func(t *testing.T, om nwenc.OffsetMapper) { testInvalidOffset(t, om, ls, size) })
+			run("PrefixSearch", func(t *testing.T, om nwenc.OffsetMapper) {
+				ps, ok := om.(nwenc.PrefixSearcher)
+				if !ok {
+					t.Skip("not a PrefixSearcher")
+				}
+				testPrefixSearch(t, ps, ls)
+			})
+		})
+	}
+}
+
+// testOffsetEncode tests OffsetEncode of the words.
+func testOffsetEncode(t *testing.T, om nwenc.OffsetMapper, ls []vocabLine) {
+	for _, l := range ls {
+		offset, err := om.OffsetEncode(l.s)
+		if err != nil {
+			t.Errorf("OffsetEncode(%q): unexpected error: %v", l.s, err)
+			continue
+		}
+		if l.offset != offset {
+			t.Errorf("OffsetEncode(%q): expected %d, but got %d", l.s, l.offset, offset)
+		}
+	}
+}
+
+// testOffsetDecode tests OffsetDecode of the offsets of the lines.
+func testOffsetDecode(t *testing.T, om nwenc.OffsetMapper, ls []vocabLine) {
+	for _, l := range ls {
+		s, err := om.OffsetDecode(l.offset)
+		if err != nil {
+			t.Errorf("OffsetDecode(%d): unexpected error: %v", l.offset, err)
+			continue
+		}
+		if l.s != s {
+			t.Errorf("OffsetDecode(%d): expected %q, but got %q", l.offset, l.s, s)
+		}
+	}
+}
+
+// testMissing tests OffsetEncode of the strings which are not lines.
+func testMissing(t *testing.T, om nwenc.OffsetMapper, ls []vocabLine) {
+	words := map[string]bool{}
+	for _, l := range ls {
+		words[l.s] = true
+	}
+
+	missing := []string{"", "\n", "\x00", "0", "aa", "zzz", "\xff"}
+	for _, l := range ls {
+		missing = append(missing,
+			l.s[:len(l.s)-1],
+			l.s[1:],
+			l.s+"a",
+			l.s+"\n",
+			"\n"+l.s,
+			l.s+"\n"+l.s,
+		)
+	}
+
+	for _, s := range missing {
+		if words[s] {
+			continue
+		}
+		_, err := om.OffsetEncode(s)
+		var eerr *nwenc.OffsetEncodeError
+		if !errors.As(err, &eerr) {
+			t.Errorf("OffsetEncode(%q): expected OffsetEncodeError, but got %v", s, err)
+		}
+	}
+}
+
+// testInvalidOffset tests OffsetDecode of the offsets which are not the beginning of a line.
+func testInvalidOffset(t *testing.T, om nwenc.OffsetMapper, ls []vocabLine, size int64) {
+	begins := map[int64]bool{}
+	for _, l := range ls {
+		begins[l.offset] = true
+	}
+
+	offsets := []int64{-1, -2, -1 << 63, size, size + 1, size + 100, 1<<63 - 1}
+	for offset := int64(0); offset < size; offset++ {
+		if !begins[offset] {
+			offsets = append(offsets, offset)
+		}
+	}
+
+	for _, offset := range offsets {
+		s, err := om.OffsetDecode(offset)
+		var derr *nwenc.OffsetDecodeError
+		if !errors.As(err, &derr) {
+			t.Errorf("OffsetDecode(%d): expected OffsetDecodeError, but got %q, %v", offset, s, err)
+		}
+	}
+}
+
+// prefixSearchOut is the result of PrefixSearch.
+type prefixSearchOut struct {
+	first, last, n int64
+}
+
+// testPrefixSearch tests PrefixSearch of the prefixes of the words and the missing ones.
+func testPrefixSearch(t *testing.T, ps nwenc.PrefixSearcher, ls []vocabLine) {
+	prefixes := map[string]bool{"": true, "\x00": true, "0": true, "zzz": true, "\xff": true}
+	for _, l := range ls {
+		for i := 1; i <= len(l.s); i++ {
+			prefixes[l.s[:i]] = true
+		}
+		prefixes[l.s+"a"] = true
+	}
+
+	for prefix := range prefixes {
+		var expected prefixSearchOut
+		for _, l := range ls {
+			if !strings.HasPrefix(l.s, prefix) {
+				continue
+			}
+			if expected.n == 0 {
+				expected.first = l.offset
+			}
+			expected.last = l.offset
+			expected.n++
+		}
+
+		first, last, n, err := ps.PrefixSearch(prefix)
+		if expected.n == 0 {
+			var eerr *nwenc.OffsetEncodeError
+			if !errors.As(err, &eerr) {
+				t.Errorf("PrefixSearch(%q): expected OffsetEncodeError, but got %v", prefix, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("PrefixSearch(%q): unexpected error: %v", prefix, err)
+			continue
+		}
+		if got := (prefixSearchOut{first, last, n}); expected != got {
+			t.Errorf("PrefixSearch(%q): expected %+v, but got %+v", prefix, expected, got)
+		}
+	}
+}
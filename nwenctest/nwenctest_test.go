@@ -0,0 +1,101 @@
+package nwenctest_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/high-moctane/nwenc"
+	"github.com/high-moctane/nwenc/nwenctest"
+)
+
+func TestAllReadOffsetMapper(t *testing.T) {
+	nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+		om, err := nwenc.NewAllReadOffsetMapper(bytes.NewReader(vocab))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return om
+	})
+}
+
+func TestSeekOffsetMapper(t *testing.T) {
+	nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+		return nwenc.NewSeekOffsetMapper(bytes.NewReader(vocab), int64(len(vocab)))
+	})
+}
+
+func TestCachedSeekOffsetMapper(t *testing.T) {
+	nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+		return nwenc.NewCachedSeekOffsetMapper(bytes.NewReader(vocab), int64(len(vocab)))
+	})
+}
+
+func TestCachedSeekOffsetMapper_Bounded(t *testing.T) {
+	config := nwenc.CacheConfig{MaxEntries: 4}
+	nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+		return nwenc.NewCachedSeekOffsetMapperWithConfig(bytes.NewReader(vocab), int64(len(vocab)), config)
+	})
+}
+
+func TestMmapOffsetMapper(t *testing.T) {
+	nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+		f, err := ioutil.TempFile("", "nwenctest")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		if _, err := f.Write(vocab); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// the mapping is kept after the file is closed
+		om, err := nwenc.NewMmapOffsetMapper(f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return om
+	})
+}
+
+func TestSparseOffsetMapper(t *testing.T) {
+	for _, interval := range []int{1, 3} {
+		interval := interval
+		t.Run(fmt.Sprintf("interval=%d", interval), func(t *testing.T) {
+			nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+				om, err := nwenc.NewSparseOffsetMapper(bytes.NewReader(vocab), int64(len(vocab)), interval)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return om
+			})
+		})
+	}
+}
+
+func TestSparseOffsetMapperBytes(t *testing.T) {
+	nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+		om, err := nwenc.NewSparseOffsetMapperBytes(bytes.NewReader(vocab), int64(len(vocab)), 16)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return om
+	})
+}
+
+func TestIndexOffsetMapper(t *testing.T) {
+	nwenctest.TestOffsetMapper(t, func(t *testing.T, vocab []byte) nwenc.OffsetMapper {
+		index := new(bytes.Buffer)
+		if err := nwenc.WriteIndex(index, bytes.NewReader(vocab)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		om, err := nwenc.NewIndexOffsetMapper(bytes.NewReader(vocab), int64(len(vocab)), index)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return om
+	})
+}
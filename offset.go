@@ -37,3 +37,10 @@ type OffsetMapper interface {
 	OffsetEncoder
 	OffsetDecoder
 }
+
+// PrefixSearcher is the interface which can find the strings starting with a prefix.
+// The text file must be sorted, so that such strings are in a row. The first and last are
+// the offsets of the first and last strings, and the n is the number of the strings.
+type PrefixSearcher interface {
+	PrefixSearch(prefix string) (first, last, n int64, err error)
+}
@@ -2,70 +2,151 @@ package nwenc
 
 import (
 	"bufio"
+	"bytes"
 	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"unicode/utf8"
 )
 
 // AllReadOffsetMapper is one of the implementation of OffsetMapper.
 // It reads all of io.Reader in advance in order to map fast.
+// It keeps the contents in one byte slice with the offsets of the lines,
+// so it uses a little more memory than the size of the contents.
 type AllReadOffsetMapper struct {
-	sToOffset map[string]int64
-	offsetToS map[int64]string
+	data    []byte  // all of the contents
+	offsets []int64 // the offsets of lines in file order
+	sorted  []int   // the indexes of offsets in string order. It is nil when the lines are sorted.
 }
 
 // NewAllReadOffsetMapper returns an AllReadOffsetMapper.
 // This function reads all of io.Reader in advance in order to map fast.
 func NewAllReadOffsetMapper(r io.Reader) (*AllReadOffsetMapper, error) {
-	m := &AllReadOffsetMapper{
-		offsetToS: map[int64]string{},
-		sToOffset: map[string]int64{},
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
 	}
 
-	var offset int64
-	sc := bufio.NewScanner(r)
-	for sc.Scan() {
-		line := sc.Text()
-		m.sToOffset[line] = offset
-		m.offsetToS[offset] = line
-		offset += int64(len(line) + 1) // 1 means '\n'
+	m := &AllReadOffsetMapper{data: data}
+	for i := 0; i < len(data); {
+		m.offsets = append(m.offsets, int64(i))
+		n := bytes.IndexByte(data[i:], '\n')
+		if n < 0 {
+			break
+		}
+		i += n + 1
 	}
-	if sc.Err() != nil {
-		return nil, sc.Err()
+
+	// OffsetEncode needs the lines in string order
+	for i := 1; i < len(m.offsets); i++ {
+		if string(m.line(i-1)) >= string(m.line(i)) {
+			m.sorted = make([]int, len(m.offsets))
+			for j := range m.sorted {
+				m.sorted[j] = j
+			}
+			sort.SliceStable(m.sorted, func(j, k int) bool {
+				return string(m.line(m.sorted[j])) < string(m.line(m.sorted[k]))
+			})
+			break
+		}
 	}
 
 	return m, nil
 }
 
-// OffsetEncode is the implementation of OffsetEncoder. It works fast.
-// When s is not found, it will return OffsetEncodeError.
+// line returns the i-th line ('\n' is not included).
+// A '\r' before '\n' is not included either, in the same way as bufio.ScanLines.
+func (m *AllReadOffsetMapper) line(i int) []byte {
+	end := int64(len(m.data))
+	if i+1 < len(m.offsets) {
+		end = m.offsets[i+1] - 1
+	} else if end > m.offsets[i] && m.data[end-1] == '\n' {
+		end--
+	}
+	if end > m.offsets[i] && m.data[end-1] == '\r' {
+		end--
+	}
+	return m.data[m.offsets[i]:end]
+}
+
+// lineInOrder returns the i-th line in string order and its offset.
+func (m *AllReadOffsetMapper) lineInOrder(i int) (line []byte, offset int64) {
+	if m.sorted != nil {
+		i = m.sorted[i]
+	}
+	return m.line(i), m.offsets[i]
+}
+
+// OffsetEncode is the implementation of OffsetEncoder. It works fast by the binary search.
+// When s is not found or is empty, it will return OffsetEncodeError.
 func (m *AllReadOffsetMapper) OffsetEncode(s string) (offset int64, err error) {
-	var ok bool
-	offset, ok = m.sToOffset[s]
-	if !ok {
+	if s == "" {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+
+	i := sort.Search(len(m.offsets), func(i int) bool {
+		line, _ := m.lineInOrder(i)
+		return string(line) >= s
+	})
+	if i == len(m.offsets) {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+
+	line, offset := m.lineInOrder(i)
+	if string(line) != s {
 		err = &OffsetEncodeError{s: s}
 		return
 	}
 	return
 }
 
-// OffsetDecode is the implementation of OffsetDecode. It works fast.
-// When offset is not found, it will return OffsetDecodeError.
+// OffsetDecode is the implementation of OffsetDecode. It works fast by the binary search.
+// When offset is not found or points an empty line, it will return OffsetDecodeError.
 func (m *AllReadOffsetMapper) OffsetDecode(offset int64) (s string, err error) {
-	var ok bool
-	s, ok = m.offsetToS[offset]
-	if !ok {
+	i := sort.Search(len(m.offsets), func(i int) bool { return m.offsets[i] >= offset })
+	if i == len(m.offsets) || m.offsets[i] != offset || len(m.line(i)) == 0 {
 		err = &OffsetDecodeError{offset: offset}
 		return
 	}
+
+	s = string(m.line(i))
 	return
 }
 
+// PrefixSearch is the implementation of PrefixSearcher. It works fast.
+// When no string starts with prefix, it will return OffsetEncodeError.
+func (m *AllReadOffsetMapper) PrefixSearch(prefix string) (first, last, n int64, err error) {
+	// the empty lines, which are not strings, are at the beginning in string order
+	i := sort.Search(len(m.offsets), func(i int) bool {
+		line, _ := m.lineInOrder(i)
+		return len(line) > 0 && string(line) >= prefix
+	})
+	j := i + sort.Search(len(m.offsets)-i, func(j int) bool {
+		line, _ := m.lineInOrder(i + j)
+		return !bytes.HasPrefix(line, []byte(prefix))
+	})
+	if i == j {
+		err = &OffsetEncodeError{s: prefix}
+		return
+	}
+
+	_, first = m.lineInOrder(i)
+	_, last = m.lineInOrder(j - 1)
+	return first, last, int64(j - i), nil
+}
+
 // SeekOffsetMapper is the implementation of OffsetMapper.
 // It seeks file each time when OffsetEncode or OffsetDecode are called, so it works slowly.
 type SeekOffsetMapper struct {
 	r    io.ReaderAt
 	size int64
+	lax  bool // accepts the offsets in the middle of a line
 }
 
 // NewSeekOffsetMapper returns an NewSeekOffsetMapper. The size is the total bytes of r.
@@ -74,9 +155,22 @@ func NewSeekOffsetMapper(r io.ReaderAt, size int64) *SeekOffsetMapper {
 	return &SeekOffsetMapper{r: r, size: size}
 }
 
+// SetStrict sets whether OffsetDecode accepts only the offsets at the beginning of a line.
+// It is true by default. When it is false, OffsetDecode of an offset in the middle of a line
+// returns the rest of the line.
+func (om *SeekOffsetMapper) SetStrict(strict bool) {
+	om.lax = !strict
+}
+
 // OffsetEncode is the implementation of OffsetEncoder.
 // This function works slow because it needs io.ReadAt seeking each time.
+// When s is not found or is empty, it will return OffsetEncodeError.
 func (om *SeekOffsetMapper) OffsetEncode(s string) (offset int64, err error) {
+	if s == "" {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+
 	offset, ok, err := readerAtBinSearch(om.r, s, 0, om.size)
 	if err != nil {
 		return
@@ -88,6 +182,102 @@ func (om *SeekOffsetMapper) OffsetEncode(s string) (offset int64, err error) {
 	return
 }
 
+// PrefixSearch is the implementation of PrefixSearcher.
+// This function works slow because it needs io.ReadAt seeking each time.
+// When no string starts with prefix, it will return OffsetEncodeError.
+func (om *SeekOffsetMapper) PrefixSearch(prefix string) (first, last, n int64, err error) {
+	return readerAtPrefixSearch(om.r, om.size, prefix)
+}
+
+// readerAtPrefixSearch searches the lines starting with prefix from r. The size is total bytes of r.
+func readerAtPrefixSearch(r io.ReaderAt, size int64, prefix string) (first, last, n int64, err error) {
+	// the empty line, which is not a string, can be only the first line of the sorted r
+	first, err = readerAtLowerBound(r, 0, size, func(line string) bool {
+		return line != "" && line >= prefix
+	})
+	if err != nil {
+		return
+	}
+	end, err := readerAtLowerBound(r, first, size, func(line string) bool {
+		return !strings.HasPrefix(line, prefix)
+	})
+	if err != nil {
+		return
+	}
+	if first == end {
+		err = &OffsetEncodeError{s: prefix}
+		return
+	}
+
+	last, err = findBeginOfLine(r, end-1)
+	if err != nil {
+		return
+	}
+	n, err = countLines(r, first, end)
+	return
+}
+
+// readerAtLowerBound returns the offset of the first line in r from left to right offsets
+// which pred returns true. The left must be the beginning of a line. The pred must return
+// false for the lines before it returns true. It returns right when no line matches.
+func readerAtLowerBound(r io.ReaderAt, left, right int64, pred func(line string) bool) (offset int64, err error) {
+	for left < right {
+		var begin int64
+		begin, err = findBeginOfLine(r, left+(right-left)/2)
+		if err != nil {
+			return
+		}
+		var line string
+		line, err = readLine(r, begin)
+		if err != nil {
+			return
+		}
+
+		if pred(line) {
+			right = begin
+		} else {
+			left = begin + int64(len(line)) + 1 // 1 means '\n'
+			if left > right {
+				left = right
+			}
+		}
+	}
+
+	return left, nil
+}
+
+// countLines counts the lines in r from left to right offsets. The left must be the beginning
+// of a line and the right must be the end of a line.
+func countLines(r io.ReaderAt, left, right int64) (n int64, err error) {
+	buf := make([]byte, 32*1024)
+	var last byte
+	for left < right {
+		b := buf
+		if int64(len(b)) > right-left {
+			b = b[:right-left]
+		}
+		var m int
+		m, err = r.ReadAt(b, left)
+		if m < len(b) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		err = nil
+
+		n += int64(bytes.Count(b, []byte{'\n'}))
+		last = b[len(b)-1]
+		left += int64(len(b))
+	}
+
+	// the last line may not end with '\n'
+	if last != '\n' {
+		n++
+	}
+	return
+}
+
 // readerAtBinsearch searches s from r. The left and right is the offset which
 // seeks from and to. When s is found, ok will be true.
 func readerAtBinSearch(r io.ReaderAt, s string, left, right int64) (offset int64, ok bool, err error) {
@@ -130,29 +320,20 @@ func searchMidoffset(r io.ReaderAt, left, right int64) (s string, offset int64,
 }
 
 // findBeginOfLine finds the beginning of line which the line contains offset.
+// When the byte at offset is '\n', it is treated as the end of the line.
 // It returns head offset int64.
 func findBeginOfLine(r io.ReaderAt, offset int64) (first int64, err error) {
-	for i := offset; i >= 0; i-- {
-		if i == 0 {
-			first = 0
-			break
-		}
-
-		buf := make([]byte, 1)
-		if _, err = r.ReadAt(buf, i); err != nil {
+	buf := make([]byte, 1)
+	for i := offset; i > 0; i-- {
+		if _, err = r.ReadAt(buf, i-1); err != nil {
 			return
 		}
-
-		if i == offset && rune(buf[0]) == '\n' {
-			continue
-		}
 		if rune(buf[0]) == '\n' {
-			first = i + 1
-			break
+			return i, nil
 		}
 	}
 
-	return
+	return 0, nil
 }
 
 // readLine reads a line from offset to '\n' ('\n' is not included).
@@ -173,31 +354,48 @@ func readLine(r io.ReaderAt, offset int64) (s string, err error) {
 
 // OffsetDecode is the implementation of OffsetDecoder.
 // This function works slow because it needs io.ReadAt seeking each time.
+// When offset is out of the file, is not the beginning of a line in the strict mode
+// or points an empty line, it will return OffsetDecodeError.
 func (om *SeekOffsetMapper) OffsetDecode(offset int64) (s string, err error) {
-	if offset >= om.size {
+	if offset < 0 || offset >= om.size {
 		err = &OffsetDecodeError{offset: offset}
 		return
 	}
 
+	// the strict mode reads the byte before offset together
+	start := offset
+	if !om.lax && offset > 0 {
+		start--
+	}
+
 	var bufLen int64 = 32
 	line := []byte{}
 	var i int64
 	for ; ; i++ {
 		buf := make([]byte, bufLen)
-		if _, err = om.r.ReadAt(buf, offset+bufLen*i); err != nil {
+		var n int
+		if n, err = om.r.ReadAt(buf, start+bufLen*i); err != nil {
 			if err == io.EOF {
-				line = append(line, buf...)
+				line = append(line, buf[:n]...)
 				break
 			}
 			return
 		}
 		line = append(line, buf...)
 
-		if strings.ContainsRune(string(line), '\n') {
+		if strings.ContainsRune(string(line[offset-start:]), '\n') {
 			break
 		}
 	}
 
+	if start < offset {
+		if line[0] != '\n' {
+			err = &OffsetDecodeError{offset: offset}
+			return
+		}
+		line = line[1:]
+	}
+
 	if !utf8.Valid(line) {
 		err = &OffsetDecodeError{offset: offset}
 		return
@@ -215,34 +413,86 @@ func (om *SeekOffsetMapper) OffsetDecode(offset int64) (s string, err error) {
 // CachedSeekOffsetMapper is the implementation of OffsetMapper.
 // It seeks io.ReaderAt when methods are called, but caches the results.
 // It's takes shorter time rather than SeekOffsetMapper.
+// It is safe for concurrent use by multiple goroutines.
 type CachedSeekOffsetMapper struct {
-	r         io.ReaderAt
-	size      int64
-	cacheTree *offsetNode
-	cacheMap  map[int64]string
+	// statistics accessed atomically. They are at the top of the struct for 64-bit alignment.
+	hits, misses, evictions uint64
+
+	r      io.ReaderAt
+	size   int64
+	config CacheConfig
+	lax    bool // accepts the offsets in the middle of a line
+
+	mu        sync.RWMutex // guards the fields below
+	cacheTree *offsetNode  // the lines found by OffsetEncode
+	cacheMap  map[int64]*cacheEntry
+	policy    cachePolicy
+	bytes     int64
+
+	encodeFlight flightGroup
+	decodeFlight flightGroup
 }
 
 // NewCachedSeekOffsetMapper returns a CachedSeekOffsetMapper. The size is total bytes of r.
 // It seeks io.ReaderAt when methods are called, but caches the results.
 // It's takes shorter time rather than SeekOffsetMapper.
+// The cache grows without limit. Use NewCachedSeekOffsetMapperWithConfig to bound it.
 func NewCachedSeekOffsetMapper(r io.ReaderAt, size int64) *CachedSeekOffsetMapper {
+	return NewCachedSeekOffsetMapperWithConfig(r, size, CacheConfig{})
+}
+
+// NewCachedSeekOffsetMapperWithConfig returns a CachedSeekOffsetMapper whose cache is
+// bounded by config. The size is total bytes of r.
+func NewCachedSeekOffsetMapperWithConfig(r io.ReaderAt, size int64, config CacheConfig) *CachedSeekOffsetMapper {
 	return &CachedSeekOffsetMapper{
 		r:         r,
 		size:      size,
+		config:    config,
 		cacheTree: nil,
-		cacheMap:  map[int64]string{},
+		cacheMap:  map[int64]*cacheEntry{},
+		policy:    newCachePolicy(config.Policy),
 	}
 }
 
+// SetStrict sets whether OffsetDecode accepts only the offsets at the beginning of a line
+// in the same way as SeekOffsetMapper.SetStrict. It is true by default.
+// It must be called before the CachedSeekOffsetMapper is used because the cache keeps
+// the results of the previous mode.
+func (om *CachedSeekOffsetMapper) SetStrict(strict bool) {
+	om.lax = !strict
+}
+
 // OffsetEncode is the implementation of OffsetEncoder.
 // This method makes cache when it is called.
+// Concurrent calls with the same s which miss the cache share one search.
+// When s is not found or is empty, it will return OffsetEncodeError.
 func (om *CachedSeekOffsetMapper) OffsetEncode(s string) (offset int64, err error) {
+	if s == "" {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+
+	om.mu.RLock()
 	offset, left, right, ok := om.cacheTree.searchString(s, 0, om.size)
 	if ok {
+		om.policy.touch(om.cacheMap[offset])
+	}
+	om.mu.RUnlock()
+	if ok {
+		atomic.AddUint64(&om.hits, 1)
 		return
 	}
+	atomic.AddUint64(&om.misses, 1)
 
-	// binary search
+	_, offset, err = om.encodeFlight.do(s, func() (string, int64, error) {
+		offset, err := om.binSearch(s, left, right)
+		return s, offset, err
+	})
+	return
+}
+
+// binSearch searches s from left to right offsets and adds the lines it reads into the cache.
+func (om *CachedSeekOffsetMapper) binSearch(s string, left, right int64) (offset int64, err error) {
 	var midS string
 	for left+1 < right {
 		midS, offset, err = searchMidoffset(om.r, left, right)
@@ -250,7 +500,9 @@ func (om *CachedSeekOffsetMapper) OffsetEncode(s string) (offset int64, err erro
 			return
 		}
 
-		om.cacheTree = om.cacheTree.add(midS, offset)
+		om.mu.Lock()
+		om.addCache(midS, offset, true)
+		om.mu.Unlock()
 
 		if s == midS {
 			return
@@ -266,37 +518,113 @@ func (om *CachedSeekOffsetMapper) OffsetEncode(s string) (offset int64, err erro
 }
 
 // OffsetDecode is the implementation of OffsetDecoder.
+// Concurrent calls with the same offset which miss the cache share one seek.
 func (om *CachedSeekOffsetMapper) OffsetDecode(offset int64) (s string, err error) {
-	s, ok := om.cacheMap[offset]
+	om.mu.RLock()
+	e, ok := om.cacheMap[offset]
 	if ok {
-		return
+		om.policy.touch(e)
+		s = e.s
 	}
-	s, _, _, ok = om.cacheTree.searchoffset(offset, 0, om.size)
+	om.mu.RUnlock()
 	if ok {
+		atomic.AddUint64(&om.hits, 1)
 		return
 	}
+	atomic.AddUint64(&om.misses, 1)
 
-	som := NewSeekOffsetMapper(om.r, om.size)
-	s, err = som.OffsetDecode(offset)
-	if err != nil {
-		return
-	}
+	s, _, err = om.decodeFlight.do(strconv.FormatInt(offset, 10), func() (string, int64, error) {
+		som := NewSeekOffsetMapper(om.r, om.size)
+		som.lax = om.lax
+		s, err := som.OffsetDecode(offset)
+		if err != nil {
+			return "", offset, err
+		}
 
-	om.cacheMap[offset] = s
+		om.mu.Lock()
+		om.addCache(s, offset, false)
+		om.mu.Unlock()
+		return s, offset, nil
+	})
 	return
 }
 
-// offsetNode is the node which consists a binary search tree.
+// PrefixSearch is the implementation of PrefixSearcher.
+// It seeks io.ReaderAt each time and does not use the cache.
+// When no string starts with prefix, it will return OffsetEncodeError.
+func (om *CachedSeekOffsetMapper) PrefixSearch(prefix string) (first, last, n int64, err error) {
+	return readerAtPrefixSearch(om.r, om.size, prefix)
+}
+
+// addCache caches s at offset and evicts entries while the cache is over the limits.
+// When inTree is true, s is also added into cacheTree for OffsetEncode.
+// The caller must hold the write lock.
+func (om *CachedSeekOffsetMapper) addCache(s string, offset int64, inTree bool) {
+	e, ok := om.cacheMap[offset]
+	if !ok {
+		e = &cacheEntry{s: s, offset: offset}
+		om.cacheMap[offset] = e
+		om.bytes += e.size()
+		om.policy.push(e)
+	}
+	if inTree && !e.inTree {
+		e.inTree = true
+		om.cacheTree = om.cacheTree.add(s, offset)
+	}
+
+	for om.overLimit() {
+		e := om.policy.evict()
+		delete(om.cacheMap, e.offset)
+		if e.inTree {
+			om.cacheTree = om.cacheTree.remove(e.s)
+		}
+		om.bytes -= e.size()
+		atomic.AddUint64(&om.evictions, 1)
+	}
+}
+
+// overLimit reports whether the cache is over the limits of the config.
+// The caller must hold the lock.
+func (om *CachedSeekOffsetMapper) overLimit() bool {
+	if len(om.cacheMap) == 0 {
+		return false
+	}
+	if om.config.MaxEntries > 0 && len(om.cacheMap) > om.config.MaxEntries {
+		return true
+	}
+	if om.config.MaxBytes > 0 && om.bytes > om.config.MaxBytes {
+		return true
+	}
+	return false
+}
+
+// Stats returns the current statistics of the cache.
+func (om *CachedSeekOffsetMapper) Stats() CacheStats {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+
+	return CacheStats{
+		Entries:   len(om.cacheMap),
+		Bytes:     om.bytes,
+		Hits:      atomic.LoadUint64(&om.hits),
+		Misses:    atomic.LoadUint64(&om.misses),
+		Evictions: atomic.LoadUint64(&om.evictions),
+	}
+}
+
+// offsetNode is the node which consists an AVL tree, which is a self-balancing binary search tree.
+// The tree keeps O(log n) height even if the nodes are added in sorted order.
 type offsetNode struct {
 	s           string
 	offset      int64
+	height      int
 	left, right *offsetNode
 }
 
 // add adds new node into pn. The caller must update pn by root.
 func (pn *offsetNode) add(s string, offset int64) (root *offsetNode) {
 	if pn == nil {
-		root = &offsetNode{s: s, offset: offset}
+		root = &offsetNode{s: s, offset: offset, height: 1}
 		return
 	}
 
@@ -304,10 +632,100 @@ func (pn *offsetNode) add(s string, offset int64) (root *offsetNode) {
 		pn.left = pn.left.add(s, offset)
 	} else if s > pn.s {
 		pn.right = pn.right.add(s, offset)
+	} else {
+		return pn
+	}
+	return pn.rebalance()
+}
+
+// remove removes the node of s from pn. The caller must update pn by root.
+func (pn *offsetNode) remove(s string) (root *offsetNode) {
+	if pn == nil {
+		return nil
+	}
+
+	if s < pn.s {
+		pn.left = pn.left.remove(s)
+		return pn.rebalance()
+	} else if s > pn.s {
+		pn.right = pn.right.remove(s)
+		return pn.rebalance()
+	}
+
+	if pn.left == nil {
+		return pn.right
+	} else if pn.right == nil {
+		return pn.left
+	}
+
+	// replace pn with the smallest node of the right subtree
+	min := pn.right
+	for min.left != nil {
+		min = min.left
+	}
+	min.right = pn.right.remove(min.s)
+	min.left = pn.left
+	return min.rebalance()
+}
+
+// nodeHeight returns the height of pn. The height of nil is 0.
+func (pn *offsetNode) nodeHeight() int {
+	if pn == nil {
+		return 0
+	}
+	return pn.height
+}
+
+// rebalance updates the height of pn and rotates pn when its subtrees are unbalanced.
+// The caller must update pn by root.
+func (pn *offsetNode) rebalance() (root *offsetNode) {
+	pn.updateHeight()
+
+	switch diff := pn.left.nodeHeight() - pn.right.nodeHeight(); {
+	case diff > 1:
+		if pn.left.left.nodeHeight() < pn.left.right.nodeHeight() {
+			pn.left = pn.left.rotateLeft()
+		}
+		return pn.rotateRight()
+	case diff < -1:
+		if pn.right.right.nodeHeight() < pn.right.left.nodeHeight() {
+			pn.right = pn.right.rotateRight()
+		}
+		return pn.rotateLeft()
 	}
 	return pn
 }
 
+// rotateLeft makes the right child of pn the new root. The caller must update pn by root.
+func (pn *offsetNode) rotateLeft() (root *offsetNode) {
+	root = pn.right
+	pn.right = root.left
+	root.left = pn
+	pn.updateHeight()
+	root.updateHeight()
+	return
+}
+
+// rotateRight makes the left child of pn the new root. The caller must update pn by root.
+func (pn *offsetNode) rotateRight() (root *offsetNode) {
+	root = pn.left
+	pn.left = root.right
+	root.right = pn
+	pn.updateHeight()
+	root.updateHeight()
+	return
+}
+
+// updateHeight updates the height of pn by its children.
+func (pn *offsetNode) updateHeight() {
+	l, r := pn.left.nodeHeight(), pn.right.nodeHeight()
+	if l > r {
+		pn.height = l + 1
+	} else {
+		pn.height = r + 1
+	}
+}
+
 // searchString searches s from the range of inLeft to inRight offsets.
 func (pn *offsetNode) searchString(s string, inLeft, inRight int64) (offset, left, right int64, ok bool) {
 	f := func(node *offsetNode) int {
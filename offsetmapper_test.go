@@ -1,10 +1,16 @@
 package nwenc
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -16,15 +22,30 @@ func TestNewAllReadOffsetMapper(t *testing.T) {
 		{
 			"a\n",
 			&AllReadOffsetMapper{
-				offsetToS: map[int64]string{0: "a"},
-				sToOffset: map[string]int64{"a": 0},
+				data:    []byte("a\n"),
+				offsets: []int64{0},
 			},
 		},
 		{
 			"a\nbcd\nefg\nhijk\n",
 			&AllReadOffsetMapper{
-				offsetToS: map[int64]string{0: "a", 2: "bcd", 6: "efg", 10: "hijk"},
-				sToOffset: map[string]int64{"a": 0, "bcd": 2, "efg": 6, "hijk": 10},
+				data:    []byte("a\nbcd\nefg\nhijk\n"),
+				offsets: []int64{0, 2, 6, 10},
+			},
+		},
+		{
+			"a\nbcd\nefg\nhijk",
+			&AllReadOffsetMapper{
+				data:    []byte("a\nbcd\nefg\nhijk"),
+				offsets: []int64{0, 2, 6, 10},
+			},
+		},
+		{
+			"efg\na\nhijk\nbcd\n",
+			&AllReadOffsetMapper{
+				data:    []byte("efg\na\nhijk\nbcd\n"),
+				offsets: []int64{0, 4, 6, 11},
+				sorted:  []int{1, 3, 0, 2},
 			},
 		},
 	}
@@ -43,6 +64,48 @@ func TestNewAllReadOffsetMapper(t *testing.T) {
 	}
 }
 
+func TestAllReadOffsetMapper_Unsorted(t *testing.T) {
+	m, err := NewAllReadOffsetMapper(strings.NewReader("efg\na\nhijk\nbcd\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for s, offset := range map[string]int64{"efg": 0, "a": 4, "hijk": 6, "bcd": 11} {
+		if got, err := m.OffsetEncode(s); got != offset || err != nil {
+			t.Errorf("%#v: expected (%d, %v), but got (%d, %v)", s, offset, nil, got, err)
+		}
+		if got, err := m.OffsetDecode(offset); got != s || err != nil {
+			t.Errorf("%d: expected (%#v, %v), but got (%#v, %v)", offset, s, nil, got, err)
+		}
+	}
+	if _, err := m.OffsetEncode("c"); !reflect.DeepEqual(&OffsetEncodeError{s: "c"}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetEncodeError{s: "c"}, err)
+	}
+}
+
+func TestAllReadOffsetMapper_CRLF(t *testing.T) {
+	// the '\r' before '\n' is stripped as bufio.ScanLines does
+	m, err := NewAllReadOffsetMapper(strings.NewReader("a\r\nbcd\r\nefg\r\nhijk\r"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for s, offset := range map[string]int64{"a": 0, "bcd": 3, "efg": 8, "hijk": 13} {
+		if got, err := m.OffsetEncode(s); got != offset || err != nil {
+			t.Errorf("%#v: expected (%d, %v), but got (%d, %v)", s, offset, nil, got, err)
+		}
+		if got, err := m.OffsetDecode(offset); got != s || err != nil {
+			t.Errorf("%d: expected (%#v, %v), but got (%#v, %v)", offset, s, nil, got, err)
+		}
+	}
+	if first, last, n, err := m.PrefixSearch("a"); first != 0 || last != 0 || n != 1 || err != nil {
+		t.Errorf("expected (%d, %d, %d, %v), but got (%d, %d, %d, %v)", 0, 0, 1, nil, first, last, n, err)
+	}
+	if _, err := m.OffsetEncode("a\r"); !reflect.DeepEqual(&OffsetEncodeError{s: "a\r"}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetEncodeError{s: "a\r"}, err)
+	}
+}
+
 func TestAllReadOffsetMapper_OffsetEncode(t *testing.T) {
 	type outType struct {
 		offset int64
@@ -183,6 +246,103 @@ func TestAllReadOffsetMapper_OffsetDecode(t *testing.T) {
 	}
 }
 
+// prefixSearchOut is the output of PrefixSearcher.
+type prefixSearchOut struct {
+	first, last, n int64
+	err            error
+}
+
+// prefixSearchTests is the test cases of PrefixSearcher for testdata/words.txt.
+var prefixSearchTests = []struct {
+	in  string
+	out prefixSearchOut
+}{
+	{
+		"",
+		prefixSearchOut{0, 61, 8, nil},
+	},
+	{
+		"a",
+		prefixSearchOut{0, 38, 3, nil},
+	},
+	{
+		"aa",
+		prefixSearchOut{2, 2, 1, nil},
+	},
+	{
+		"ab",
+		prefixSearchOut{38, 38, 1, nil},
+	},
+	{
+		"bcd",
+		prefixSearchOut{43, 43, 1, nil},
+	},
+	{
+		"de",
+		prefixSearchOut{47, 53, 2, nil},
+	},
+	{
+		"ijk",
+		prefixSearchOut{57, 61, 2, nil},
+	},
+	{
+		"ijkl",
+		prefixSearchOut{61, 61, 1, nil},
+	},
+	{
+		"0",
+		prefixSearchOut{0, 0, 0, &OffsetEncodeError{s: "0"}},
+	},
+	{
+		"c",
+		prefixSearchOut{0, 0, 0, &OffsetEncodeError{s: "c"}},
+	},
+	{
+		"ijklm",
+		prefixSearchOut{0, 0, 0, &OffsetEncodeError{s: "ijklm"}},
+	},
+	{
+		"z",
+		prefixSearchOut{0, 0, 0, &OffsetEncodeError{s: "z"}},
+	},
+}
+
+// testPrefixSearch runs prefixSearchTests against ps.
+func testPrefixSearch(t *testing.T, ps PrefixSearcher) {
+	t.Helper()
+
+	for idx, test := range prefixSearchTests {
+		first, last, n, err := ps.PrefixSearch(test.in)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		out := prefixSearchOut{first, last, n, nil}
+		if test.out != out {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, out)
+		}
+	}
+}
+
+func TestAllReadOffsetMapper_PrefixSearch(t *testing.T) {
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	om, err := NewAllReadOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testPrefixSearch(t, om)
+}
+
 func BenchmarkAllReadOffsetMapper_OffsetEncode(b *testing.B) {
 	queries := []string{
 		"a",
@@ -232,6 +392,74 @@ func BenchmarkAllReadOffsetMapper_OffsetDecode(b *testing.B) {
 	}
 }
 
+// benchmarkVocabulary returns a sorted text file of n words.
+func benchmarkVocabulary(n int) []byte {
+	buf := new(bytes.Buffer)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(buf, "word%08d\n", i)
+	}
+	return buf.Bytes()
+}
+
+// retainedHeap returns the heap bytes which the result of fn retains.
+func retainedHeap(fn func() interface{}) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := fn()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+func BenchmarkNewAllReadOffsetMapper(b *testing.B) {
+	data := benchmarkVocabulary(100000)
+
+	layouts := []struct {
+		name string
+		new  func() interface{}
+	}{
+		{
+			"compact",
+			func() interface{} {
+				m, err := NewAllReadOffsetMapper(bytes.NewReader(data))
+				if err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				return m
+			},
+		},
+		{
+			// the former layout which keeps each line twice in two maps
+			"maps",
+			func() interface{} {
+				sToOffset := map[string]int64{}
+				offsetToS := map[int64]string{}
+				var offset int64
+				sc := bufio.NewScanner(bytes.NewReader(data))
+				for sc.Scan() {
+					line := sc.Text()
+					sToOffset[line] = offset
+					offsetToS[offset] = line
+					offset += int64(len(line) + 1)
+				}
+				return []interface{}{sToOffset, offsetToS}
+			},
+		},
+	}
+
+	for _, layout := range layouts {
+		b.Run(layout.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(retainedHeap(layout.new))/float64(len(data)), "heap/file-byte")
+			for i := 0; i < b.N; i++ {
+				layout.new()
+			}
+		})
+	}
+}
+
 func TestSeekOffsetMapper_OffsetEncode(t *testing.T) {
 	type outType struct {
 		offset int64
@@ -319,6 +547,53 @@ func TestSeekOffsetMapper_OffsetEncode(t *testing.T) {
 	}
 }
 
+func TestSeekOffsetMapper_PrefixSearch(t *testing.T) {
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testPrefixSearch(t, NewSeekOffsetMapper(f, info.Size()))
+}
+
+func TestSeekOffsetMapper_PrefixSearchNoNewline(t *testing.T) {
+	tests := []struct {
+		in  string
+		out prefixSearchOut
+	}{
+		{
+			"a",
+			prefixSearchOut{0, 3, 2, nil},
+		},
+		{
+			"b",
+			prefixSearchOut{6, 9, 2, nil},
+		},
+		{
+			"bc",
+			prefixSearchOut{9, 9, 1, nil},
+		},
+	}
+
+	// the last line does not end with '\n'
+	r := strings.NewReader("ab\nac\nbb\nbcd")
+	om := NewSeekOffsetMapper(r, r.Size())
+
+	for idx, test := range tests {
+		first, last, n, err := om.PrefixSearch(test.in)
+		out := prefixSearchOut{first, last, n, err}
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, out)
+		}
+	}
+}
+
 func BenchmarkSeekOffsetMapper_OffsetEncode(b *testing.B) {
 	queries := []string{
 		"a",
@@ -372,6 +647,15 @@ func TestSeekOffsetMapper_OffsetDecode(t *testing.T) {
 		{
 			38, outType{"abcd", nil},
 		},
+		{
+			-1, outType{"", &OffsetDecodeError{offset: -1}},
+		},
+		{
+			1, outType{"", &OffsetDecodeError{offset: 1}},
+		},
+		{
+			3, outType{"", &OffsetDecodeError{offset: 3}},
+		},
 		{
 			37, outType{"", &OffsetDecodeError{offset: 37}},
 		},
@@ -409,6 +693,43 @@ func TestSeekOffsetMapper_OffsetDecode(t *testing.T) {
 	}
 }
 
+func TestSeekOffsetMapper_SetStrict(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type strictOffsetDecoder interface {
+		OffsetDecoder
+		SetStrict(strict bool)
+	}
+	mappers := []strictOffsetDecoder{
+		NewSeekOffsetMapper(f, info.Size()),
+		NewCachedSeekOffsetMapper(f, info.Size()),
+	}
+
+	for idx, om := range mappers {
+		om.SetStrict(false)
+
+		// the rest of the line
+		if s, err := om.OffsetDecode(3); s != "aaabbbbccccddddeeeeffffgggghhhhiii" || err != nil {
+			t.Errorf("[%d] expected %q, but got %q, %v", idx, "aaabbbbccccddddeeeeffffgggghhhhiii", s, err)
+		}
+		// the newline is still an empty line
+		if _, err := om.OffsetDecode(1); !reflect.DeepEqual(&OffsetDecodeError{offset: 1}, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, &OffsetDecodeError{offset: 1}, err)
+		}
+		if s, err := om.OffsetDecode(38); s != "abcd" || err != nil {
+			t.Errorf("[%d] expected %q, but got %q, %v", idx, "abcd", s, err)
+		}
+	}
+}
+
 func BenchmarkSeekOffsetMapper_OffsetDecode(b *testing.B) {
 	queries := []int64{0, 2, 38, 43, 47, 53, 57, 61}
 
@@ -522,6 +843,21 @@ func TestCachedSeekOffsetMapper_OffsetEncode(t *testing.T) {
 	}
 }
 
+func TestCachedSeekOffsetMapper_PrefixSearch(t *testing.T) {
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testPrefixSearch(t, NewCachedSeekOffsetMapper(f, info.Size()))
+}
+
 func BenchmarkCachedSeekOffsetMapper_OffsetEncode(b *testing.B) {
 	queries := []string{
 		"a",
@@ -570,6 +906,15 @@ func TestCachedSeekOffsetMapper_OffsetDecode(t *testing.T) {
 		{
 			38, outType{"abcd", nil},
 		},
+		{
+			-1, outType{"", &OffsetDecodeError{offset: -1}},
+		},
+		{
+			1, outType{"", &OffsetDecodeError{offset: 1}},
+		},
+		{
+			3, outType{"", &OffsetDecodeError{offset: 3}},
+		},
 		{
 			37, outType{"", &OffsetDecodeError{offset: 37}},
 		},
@@ -628,6 +973,208 @@ func BenchmarkCachedSeekOffsetMapper_OffsetDecode(b *testing.B) {
 	}
 }
 
+func TestCachedSeekOffsetMapper_Concurrent(t *testing.T) {
+	words := map[string]int64{
+		"a":                                   0,
+		"aaaabbbbccccddddeeeeffffgggghhhhiii": 2,
+		"abcd":                                38,
+		"bcd":                                 43,
+		"defgh":                               47,
+		"deg":                                 53,
+		"ijk":                                 57,
+		"ijkl":                                61,
+	}
+
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	om := NewCachedSeekOffsetMapper(f, info.Size())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for s, offset := range words {
+					gotOffset, err := om.OffsetEncode(s)
+					if err != nil || gotOffset != offset {
+						t.Errorf("%#v: expected %d, but got (%d, %v)", s, offset, gotOffset, err)
+					}
+					gotS, err := om.OffsetDecode(offset)
+					if err != nil || gotS != s {
+						t.Errorf("%d: expected %#v, but got (%#v, %v)", offset, s, gotS, err)
+					}
+				}
+				if _, err := om.OffsetEncode("z"); !reflect.DeepEqual(&OffsetEncodeError{s: "z"}, err) {
+					t.Errorf("expected %v, but got %v", &OffsetEncodeError{s: "z"}, err)
+				}
+				if _, err := om.OffsetDecode(66); !reflect.DeepEqual(&OffsetDecodeError{offset: 66}, err) {
+					t.Errorf("expected %v, but got %v", &OffsetDecodeError{offset: 66}, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCachedSeekOffsetMapper_Bounded(t *testing.T) {
+	words := []string{
+		"a",
+		"aaaabbbbccccddddeeeeffffgggghhhhiii",
+		"abcd",
+		"bcd",
+		"defgh",
+		"deg",
+		"ijk",
+		"ijkl",
+	}
+	offsets := []int64{0, 2, 38, 43, 47, 53, 57, 61}
+
+	tests := []CacheConfig{
+		{MaxEntries: 1, Policy: EvictLRU},
+		{MaxEntries: 3, Policy: EvictLRU},
+		{MaxEntries: 3, Policy: EvictLFU},
+		{MaxEntries: 3, Policy: EvictClock},
+		{MaxBytes: 3 * (cacheEntryOverhead + 4), Policy: EvictLRU},
+		{MaxBytes: 1, Policy: EvictClock},
+	}
+
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for idx, test := range tests {
+		om := NewCachedSeekOffsetMapperWithConfig(f, info.Size(), test)
+
+		for i := 0; i < 3; i++ {
+			for j, s := range words {
+				offset, err := om.OffsetEncode(s)
+				if err != nil || offset != offsets[j] {
+					t.Errorf("[%d] %#v: expected %d, but got (%d, %v)", idx, s, offsets[j], offset, err)
+				}
+				gotS, err := om.OffsetDecode(offsets[j])
+				if err != nil || gotS != s {
+					t.Errorf("[%d] %d: expected %#v, but got (%#v, %v)", idx, offsets[j], s, gotS, err)
+				}
+
+				stats := om.Stats()
+				if test.MaxEntries > 0 && stats.Entries > test.MaxEntries {
+					t.Errorf("[%d] entries expected <= %d, but got %d", idx, test.MaxEntries, stats.Entries)
+				}
+				if test.MaxBytes > 0 && stats.Bytes > test.MaxBytes {
+					t.Errorf("[%d] bytes expected <= %d, but got %d", idx, test.MaxBytes, stats.Bytes)
+				}
+				if stats.Entries != len(om.cacheMap) {
+					t.Errorf("[%d] entries expected %d, but got %d", idx, len(om.cacheMap), stats.Entries)
+				}
+			}
+		}
+
+		stats := om.Stats()
+		if stats.Hits+stats.Misses != uint64(3*2*len(words)) {
+			t.Errorf("[%d] hits+misses expected %d, but got %d", idx, 3*2*len(words), stats.Hits+stats.Misses)
+		}
+		if stats.Evictions == 0 {
+			t.Errorf("[%d] evictions expected > 0, but got 0", idx)
+		}
+	}
+}
+
+func TestCachedSeekOffsetMapper_Stats(t *testing.T) {
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	om := NewCachedSeekOffsetMapper(f, info.Size())
+
+	if _, err := om.OffsetEncode("bcd"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := om.OffsetEncode("bcd"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := om.OffsetDecode(43); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := om.Stats()
+	if stats.Hits != 2 {
+		t.Errorf("hits expected %d, but got %d", 2, stats.Hits)
+	}
+	if stats.Misses != 1 {
+		t.Errorf("misses expected %d, but got %d", 1, stats.Misses)
+	}
+	var bytes int64
+	for _, e := range om.cacheMap {
+		bytes += e.size()
+	}
+	if stats.Entries == 0 {
+		t.Errorf("entries expected > 0, but got 0")
+	}
+	if stats.Bytes != bytes {
+		t.Errorf("bytes expected %d, but got %d", bytes, stats.Bytes)
+	}
+	if stats.Evictions != 0 {
+		t.Errorf("evictions expected %d, but got %d", 0, stats.Evictions)
+	}
+}
+
+func BenchmarkCachedSeekOffsetMapper_Parallel(b *testing.B) {
+	queries := []string{
+		"a",
+		"aaaabbbbccccddddeeeeffffgggghhhhiii",
+		"abcd",
+		"bcd",
+		"defgh",
+		"deg",
+		"ijk",
+		"ijkl",
+	}
+
+	// open file
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	om := NewCachedSeekOffsetMapper(f, info.Size())
+
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			offset, _ := om.OffsetEncode(queries[i%len(queries)])
+			om.OffsetDecode(offset)
+		}
+	})
+}
+
 func TestOffsetNode_Add(t *testing.T) {
 	type inType struct {
 		s      string
@@ -639,43 +1186,87 @@ func TestOffsetNode_Add(t *testing.T) {
 	}{
 		{
 			[]inType{{"a", 0}},
-			&offsetNode{s: "a", offset: 0},
+			&offsetNode{s: "a", offset: 0, height: 1},
 		},
 		{
 			[]inType{{"b", 2}, {"a", 0}, {"c", 4}},
 			&offsetNode{
 				s:      "b",
 				offset: 2,
+				height: 2,
 				left: &offsetNode{
 					s:      "a",
 					offset: 0,
+					height: 1,
 				},
 				right: &offsetNode{
 					s:      "c",
 					offset: 4,
+					height: 1,
+				},
+			},
+		},
+		{
+			[]inType{{"a", 0}, {"b", 2}, {"c", 4}},
+			&offsetNode{
+				s:      "b",
+				offset: 2,
+				height: 2,
+				left: &offsetNode{
+					s:      "a",
+					offset: 0,
+					height: 1,
+				},
+				right: &offsetNode{
+					s:      "c",
+					offset: 4,
+					height: 1,
+				},
+			},
+		},
+		{
+			[]inType{{"a", 0}, {"c", 4}, {"b", 2}},
+			&offsetNode{
+				s:      "b",
+				offset: 2,
+				height: 2,
+				left: &offsetNode{
+					s:      "a",
+					offset: 0,
+					height: 1,
+				},
+				right: &offsetNode{
+					s:      "c",
+					offset: 4,
+					height: 1,
 				},
 			},
 		},
 		{
 			[]inType{{"d", 6}, {"b", 2}, {"a", 0}, {"e", 8}, {"c", 4}},
 			&offsetNode{
-				s:      "d",
-				offset: 6,
+				s:      "b",
+				offset: 2,
+				height: 3,
 				left: &offsetNode{
-					s:      "b",
-					offset: 2,
+					s:      "a",
+					offset: 0,
+					height: 1,
+				},
+				right: &offsetNode{
+					s:      "d",
+					offset: 6,
+					height: 2,
 					left: &offsetNode{
-						s:      "a",
-						offset: 0,
-					},
-					right: &offsetNode{
 						s:      "c",
 						offset: 4,
+						height: 1,
+					},
+					right: &offsetNode{
+						s:      "e",
+						offset: 8,
+						height: 1,
 					},
-				},
-				right: &offsetNode{
-					s:      "e",
-					offset: 8,
 				},
 			},
 		},
@@ -693,6 +1284,182 @@ func TestOffsetNode_Add(t *testing.T) {
 	}
 }
 
+func TestOffsetNode_Balanced(t *testing.T) {
+	orders := map[string][]int{
+		"sorted":   sortedOrder(1000),
+		"reversed": reversedOrder(1000),
+		"zigzag":   zigzagOrder(1000),
+	}
+
+	for name, order := range orders {
+		var root *offsetNode
+		for _, i := range order {
+			root = root.add(fmt.Sprintf("%08d", i), int64(i))
+			checkOffsetNode(t, name, root)
+		}
+		// log2(1000) * 1.44 is about 14.4
+		if root.height > 14 {
+			t.Errorf("[%s] height expected <= 14, but got %d", name, root.height)
+		}
+
+		for _, i := range order[:500] {
+			root = root.remove(fmt.Sprintf("%08d", i))
+			checkOffsetNode(t, name, root)
+		}
+	}
+}
+
+// checkOffsetNode checks the order and the balance of the tree.
+func checkOffsetNode(t *testing.T, name string, pn *offsetNode) {
+	t.Helper()
+
+	var walk func(pn *offsetNode) int
+	walk = func(pn *offsetNode) int {
+		if pn == nil {
+			return 0
+		}
+		if pn.left != nil && !(pn.left.s < pn.s && pn.left.offset < pn.offset) {
+			t.Fatalf("[%s] %#v must be less than %#v", name, pn.left.s, pn.s)
+		}
+		if pn.right != nil && !(pn.right.s > pn.s && pn.right.offset > pn.offset) {
+			t.Fatalf("[%s] %#v must be greater than %#v", name, pn.right.s, pn.s)
+		}
+		l, r := walk(pn.left), walk(pn.right)
+		if l-r > 1 || r-l > 1 {
+			t.Fatalf("[%s] %#v is unbalanced: %d, %d", name, pn.s, l, r)
+		}
+		h := l + 1
+		if r > l {
+			h = r + 1
+		}
+		if pn.height != h {
+			t.Fatalf("[%s] %#v height expected %d, but got %d", name, pn.s, h, pn.height)
+		}
+		return h
+	}
+	walk(pn)
+}
+
+// sortedOrder returns 0, 1, ..., n-1.
+func sortedOrder(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// reversedOrder returns n-1, n-2, ..., 0.
+func reversedOrder(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = n - 1 - i
+	}
+	return order
+}
+
+// zigzagOrder returns 0, n-1, 1, n-2, ..., which makes an unbalanced tree zigzag.
+func zigzagOrder(n int) []int {
+	order := make([]int, 0, n)
+	for lo, hi := 0, n-1; lo <= hi; lo, hi = lo+1, hi-1 {
+		order = append(order, lo)
+		if lo != hi {
+			order = append(order, hi)
+		}
+	}
+	return order
+}
+
+func BenchmarkOffsetNode_Add(b *testing.B) {
+	const n = 10000
+	orders := []struct {
+		name  string
+		order []int
+	}{
+		{"sorted", sortedOrder(n)},
+		{"reversed", reversedOrder(n)},
+		{"zigzag", zigzagOrder(n)},
+		{"random", rand.New(rand.NewSource(1)).Perm(n)},
+	}
+
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08d", i)
+	}
+
+	for _, o := range orders {
+		b.Run(o.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var root *offsetNode
+				for _, j := range o.order {
+					root = root.add(keys[j], int64(j))
+				}
+				for _, j := range o.order {
+					root.searchString(keys[j], 0, n)
+				}
+			}
+		})
+	}
+}
+
+func TestOffsetNode_Remove(t *testing.T) {
+	type inType struct {
+		nodes  []string
+		remove []string
+	}
+	tests := []struct {
+		in  inType
+		out []string
+	}{
+		{
+			inType{[]string{"a"}, []string{"a"}},
+			[]string{},
+		},
+		{
+			inType{[]string{"b", "a", "c"}, []string{"z"}},
+			[]string{"a", "b", "c"},
+		},
+		{
+			inType{[]string{"b", "a", "c"}, []string{"b"}},
+			[]string{"a", "c"},
+		},
+		{
+			inType{[]string{"d", "b", "a", "e", "c"}, []string{"b", "e"}},
+			[]string{"a", "c", "d"},
+		},
+		{
+			inType{[]string{"d", "b", "a", "e", "c"}, []string{"d", "a", "c"}},
+			[]string{"b", "e"},
+		},
+	}
+
+	for idx, test := range tests {
+		var root *offsetNode
+		for i, s := range test.in.nodes {
+			root = root.add(s, int64(i))
+		}
+		for _, s := range test.in.remove {
+			root = root.remove(s)
+		}
+
+		out := []string{}
+		var walk func(*offsetNode)
+		walk = func(pn *offsetNode) {
+			if pn == nil {
+				return
+			}
+			walk(pn.left)
+			out = append(out, pn.s)
+			walk(pn.right)
+		}
+		walk(root)
+
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out, out)
+		}
+	}
+}
+
 func TestOffsetNode_SearchString(t *testing.T) {
 	type nodeInput struct {
 		s      string
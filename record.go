@@ -0,0 +1,297 @@
+package nwenc
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// recordKind is the kind of a field of a record.
+type recordKind int
+
+const (
+	recordWord   recordKind = iota // a string encoded to its offset
+	recordOffset                   // an int64 offset
+	recordUint                     // an unsigned integer
+	recordInt                      // a signed integer in two's complement
+)
+
+// recordField is an encoded field of a record.
+type recordField struct {
+	name  string
+	index int
+	kind  recordKind
+	width int // byte length
+}
+
+// RecordCodec encodes/decodes a struct to fixed-width bytes by the schema declared in
+// the struct tags. A tag is `nwenc:"kind,byteLen"` and the kind is one of:
+//
+//	word    a string field encoded to its offset by OffsetEncoder
+//	offset  an integer field of an offset
+//	uint    an unsigned integer field, or a signed one which is not negative
+//	int     a signed integer field in two's complement
+//
+// The byteLen must be 1 <= byteLen <= 8. The fields are encoded in the declared order
+// in big endian, and the fields without the tag or with `nwenc:"-"` are ignored.
+//
+// RecordCodec example:
+//
+//	type Trigram struct {
+//		W1    string `nwenc:"word,3"`
+//		W2    string `nwenc:"word,3"`
+//		W3    string `nwenc:"word,3"`
+//		Count uint64 `nwenc:"uint,4"`
+//	}
+//
+//	rc, err := NewRecordCodec(Trigram{})
+//	rc.Encode(w, om, &Trigram{"a", "bcd", "efg", 42}) // writes 13 bytes
+//
+//	var tri Trigram
+//	rc.Decode(r, om, &tri)
+type RecordCodec struct {
+	typ    reflect.Type
+	fields []recordField
+	size   int
+}
+
+// NewRecordCodec returns a RecordCodec of the type of v, which is a struct or a pointer to a struct.
+func NewRecordCodec(v interface{}) (*RecordCodec, error) {
+	typ := reflect.TypeOf(v)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("record must be a struct: %v", typ)
+	}
+
+	rc := &RecordCodec{typ: typ}
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag, ok := sf.Tag.Lookup("nwenc")
+		if !ok || tag == "-" {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return nil, fmt.Errorf("field %s: unexported field", sf.Name)
+		}
+
+		f, err := parseRecordTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		f.name, f.index = sf.Name, i
+		if !recordKindAccepts(f.kind, sf.Type.Kind()) {
+			return nil, fmt.Errorf("field %s: %s cannot be %v", sf.Name, tag, sf.Type)
+		}
+
+		rc.fields = append(rc.fields, f)
+		rc.size += f.width
+	}
+	if len(rc.fields) == 0 {
+		return nil, fmt.Errorf("record has no field: %v", typ)
+	}
+
+	return rc, nil
+}
+
+// parseRecordTag parses the nwenc tag of a field.
+func parseRecordTag(tag string) (f recordField, err error) {
+	i := strings.IndexByte(tag, ',')
+	if i < 0 {
+		err = fmt.Errorf("invalid tag: %q", tag)
+		return
+	}
+
+	switch tag[:i] {
+	case "word":
+		f.kind = recordWord
+	case "offset":
+		f.kind = recordOffset
+	case "uint":
+		f.kind = recordUint
+	case "int":
+		f.kind = recordInt
+	default:
+		err = fmt.Errorf("invalid tag: %q", tag)
+		return
+	}
+
+	f.width, err = strconv.Atoi(tag[i+1:])
+	if err != nil || f.width < 1 || 8 < f.width {
+		err = fmt.Errorf("invalid byte length: %q", tag)
+		return
+	}
+	return
+}
+
+// recordKindAccepts returns whether a field of the kind k can be encoded as rk.
+func recordKindAccepts(rk recordKind, k reflect.Kind) bool {
+	switch k {
+	case reflect.String:
+		return rk == recordWord
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rk != recordWord
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rk == recordOffset || rk == recordUint
+	default:
+		return false
+	}
+}
+
+// Size returns the byte length of a record.
+func (rc *RecordCodec) Size() int {
+	return rc.size
+}
+
+// Encode encodes the record v and writes it to w. The v must be the struct or a pointer to it.
+// The oe encodes the word fields and may be nil when there is no word field.
+func (rc *RecordCodec) Encode(w io.Writer, oe OffsetEncoder, v interface{}) error {
+	b, err := rc.AppendEncode(make([]byte, 0, rc.size), oe, v)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AppendEncode appends the encoded bytes of the record v to dst and returns the extended slice.
+// When a field cannot be encoded, it will return dst and the error.
+func (rc *RecordCodec) AppendEncode(dst []byte, oe OffsetEncoder, v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Type() != rc.typ {
+		return dst, fmt.Errorf("record must be %v: %T", rc.typ, v)
+	}
+
+	n := len(dst)
+	for _, f := range rc.fields {
+		u, err := rc.encodeField(oe, f, rv.Field(f.index))
+		if err != nil {
+			return dst[:n], fmt.Errorf("field %s: %w", f.name, err)
+		}
+		for i := f.width - 1; i >= 0; i-- {
+			dst = append(dst, byte(u>>uint(8*i)))
+		}
+	}
+	return dst, nil
+}
+
+// encodeField returns the bits of the field v.
+func (rc *RecordCodec) encodeField(oe OffsetEncoder, f recordField, v reflect.Value) (uint64, error) {
+	bitLen := 8 * f.width
+
+	var x int64
+	switch v.Kind() {
+	case reflect.String:
+		offset, err := oe.OffsetEncode(v.String())
+		if err != nil {
+			return 0, err
+		}
+		x = offset
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		if f.kind == recordUint {
+			if bitLen < 64 && u >= 1<<uint(bitLen) {
+				return 0, fmt.Errorf("value out of range of %d bits: %v", bitLen, u)
+			}
+			return u, nil
+		}
+		x = int64(u) // a negative x is out of range of offsets
+	default:
+		x = v.Int()
+	}
+
+	switch f.kind {
+	case recordInt:
+		if bitLen < 64 && (x < -1<<uint(bitLen-1) || x >= 1<<uint(bitLen-1)) {
+			return 0, fmt.Errorf("value out of range of %d bits: %v", bitLen, x)
+		}
+		return uint64(x), nil
+	case recordUint:
+		if x < 0 || (bitLen < 64 && x >= 1<<uint(bitLen)) {
+			return 0, fmt.Errorf("value out of range of %d bits: %v", bitLen, x)
+		}
+		return uint64(x), nil
+	default:
+		if x < 0 || (bitLen < 64 && x >= 1<<uint(bitLen)) {
+			return 0, &BitRangeError{offset: x, bitLen: bitLen}
+		}
+		return uint64(x), nil
+	}
+}
+
+// Decode reads a record from r and decodes it to v, which must be a pointer to the struct.
+// The od decodes the word fields and may be nil when there is no word field.
+// It returns io.EOF only when no byte is read and io.ErrUnexpectedEOF when the record is truncated.
+func (rc *RecordCodec) Decode(r io.Reader, od OffsetDecoder, v interface{}) error {
+	b := make([]byte, rc.size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	return rc.DecodeBytes(b, od, v)
+}
+
+// DecodeBytes decodes the record in b to v, which must be a pointer to the struct.
+// The length of b must be Size.
+func (rc *RecordCodec) DecodeBytes(b []byte, od OffsetDecoder, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Type() != rc.typ {
+		return fmt.Errorf("record must be a non-nil pointer to %v: %T", rc.typ, v)
+	}
+	if len(b) != rc.size {
+		return fmt.Errorf("record must be %d bytes: %d", rc.size, len(b))
+	}
+	rv = rv.Elem()
+
+	for _, f := range rc.fields {
+		var u uint64
+		for _, c := range b[:f.width] {
+			u = u<<8 | uint64(c)
+		}
+		b = b[f.width:]
+
+		if err := rc.decodeField(od, f, u, rv.Field(f.index)); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// decodeField sets the bits u to the field v.
+func (rc *RecordCodec) decodeField(od OffsetDecoder, f recordField, u uint64, v reflect.Value) error {
+	bitLen := 8 * f.width
+
+	switch v.Kind() {
+	case reflect.String:
+		s, err := od.OffsetDecode(int64(u))
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.OverflowUint(u) {
+			return fmt.Errorf("value overflows %v: %v", v.Type(), u)
+		}
+		v.SetUint(u)
+		return nil
+	}
+
+	x := int64(u)
+	if f.kind == recordInt && bitLen < 64 {
+		// sign extension
+		x = int64(u<<uint(64-bitLen)) >> uint(64-bitLen)
+	}
+	if v.OverflowInt(x) || (f.kind != recordInt && x < 0) {
+		return fmt.Errorf("value overflows %v: %v", v.Type(), u)
+	}
+	v.SetInt(x)
+	return nil
+}
@@ -0,0 +1,283 @@
+package nwenc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testTrigram struct {
+	W1    string `nwenc:"word,3"`
+	W2    string `nwenc:"word,2"`
+	W3    string `nwenc:"word,1"`
+	Count uint64 `nwenc:"uint,4"`
+	Score int16  `nwenc:"int,2"`
+	Next  int64  `nwenc:"offset,3"`
+	Note  string // ignored
+	Skip  int    `nwenc:"-"`
+}
+
+func TestNewRecordCodec(t *testing.T) {
+	type valid struct {
+		A int    `nwenc:"int,8"`
+		B uint8  `nwenc:"uint,1"`
+		C string `nwenc:"word,8"`
+		D uint32 `nwenc:"offset,4"`
+		e int
+	}
+	rc, err := NewRecordCodec(&valid{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rc.Size() != 21 {
+		t.Errorf("expected %d, but got %d", 21, rc.Size())
+	}
+	rc, err = NewRecordCodec(testTrigram{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rc.Size() != 15 {
+		t.Errorf("expected %d, but got %d", 15, rc.Size())
+	}
+
+	tests := []struct {
+		in  interface{}
+		err string
+	}{
+		{
+			nil,
+			"record must be a struct: <nil>",
+		},
+		{
+			1,
+			"record must be a struct: int",
+		},
+		{
+			struct{ A int }{},
+			"record has no field: struct { A int }",
+		},
+		{
+			struct {
+				A int `nwenc:"int"`
+			}{},
+			`field A: invalid tag: "int"`,
+		},
+		{
+			struct {
+				A int `nwenc:"float,4"`
+			}{},
+			`field A: invalid tag: "float,4"`,
+		},
+		{
+			struct {
+				A int `nwenc:"int,9"`
+			}{},
+			`field A: invalid byte length: "int,9"`,
+		},
+		{
+			struct {
+				A int `nwenc:"int,x"`
+			}{},
+			`field A: invalid byte length: "int,x"`,
+		},
+		{
+			struct {
+				A string `nwenc:"uint,2"`
+			}{},
+			"field A: uint,2 cannot be string",
+		},
+		{
+			struct {
+				A int `nwenc:"word,2"`
+			}{},
+			"field A: word,2 cannot be int",
+		},
+		{
+			struct {
+				A uint `nwenc:"int,2"`
+			}{},
+			"field A: int,2 cannot be uint",
+		},
+		{
+			struct {
+				a int `nwenc:"int,2"`
+			}{},
+			"field a: unexported field",
+		},
+	}
+
+	for idx, test := range tests {
+		_, err := NewRecordCodec(test.in)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.err, err)
+		}
+	}
+}
+
+func TestRecordCodec_Encode(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	om, err := NewAllReadOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rc, err := NewRecordCodec(testTrigram{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type outType struct {
+		buf []byte
+		err string
+	}
+	tests := []struct {
+		in  testTrigram
+		out outType
+	}{
+		{
+			testTrigram{"bcd", "a", "defgh", 0x01020304, -2, 0x0A0B0C, "x", 1},
+			outType{[]byte{0, 0, 43, 0, 0, 47, 1, 2, 3, 4, 0xFF, 0xFE, 0x0A, 0x0B, 0x0C}, ""},
+		},
+		{
+			testTrigram{"a", "a", "a", 0xFFFFFFFF, 0x7FFF, 0xFFFFFF, "", 0},
+			outType{[]byte{0, 0, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF}, ""},
+		},
+		{
+			testTrigram{"a", "zzz", "a", 0, 0, 0, "", 0},
+			outType{nil, `field W2: string cannot encode: "zzz"`},
+		},
+		{
+			testTrigram{"a", "a", "a", 0x100000000, 0, 0, "", 0},
+			outType{nil, "field Count: value out of range of 32 bits: 4294967296"},
+		},
+		{
+			testTrigram{"a", "a", "a", 0, 0, 0x1000000, "", 0},
+			outType{nil, "field Next: offset out of range of 24 bits: 16777216"},
+		},
+		{
+			testTrigram{"a", "a", "a", 0, 0, -1, "", 0},
+			outType{nil, "field Next: offset out of range of 24 bits: -1"},
+		},
+	}
+
+	for idx, test := range tests {
+		buf := new(bytes.Buffer)
+		err := rc.Encode(buf, om, test.in)
+		if test.out.err != "" {
+			if err == nil || err.Error() != test.out.err {
+				t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("[%d] expected no output, but got %v", idx, buf.Bytes())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out.buf, buf.Bytes()) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.buf, buf.Bytes())
+		}
+
+		var out testTrigram
+		if err := rc.Decode(buf, om, &out); err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+		}
+		expected := test.in
+		expected.Note, expected.Skip = "", 0
+		if expected != out {
+			t.Errorf("[%d] expected %+v, but got %+v", idx, expected, out)
+		}
+	}
+
+	// the cause of the error is kept
+	_, err = rc.AppendEncode(nil, om, &testTrigram{W1: "zzz"})
+	var eerr *OffsetEncodeError
+	if !errors.As(err, &eerr) {
+		t.Errorf("expected OffsetEncodeError, but got %v", err)
+	}
+	if _, err := rc.AppendEncode(nil, om, struct{}{}); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+	if _, err := rc.AppendEncode(nil, om, (*testTrigram)(nil)); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+}
+
+func TestRecordCodec_Decode(t *testing.T) {
+	om, err := NewAllReadOffsetMapper(strings.NewReader("a\nbcd\nefg\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	type record struct {
+		W string `nwenc:"word,1"`
+		N int8   `nwenc:"uint,1"`
+		I int32  `nwenc:"int,1"`
+	}
+	rc, err := NewRecordCodec(record{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type outType struct {
+		rec record
+		err string
+	}
+	tests := []struct {
+		in  []byte
+		out outType
+	}{
+		{
+			[]byte{2, 0x7F, 0x80},
+			outType{record{"bcd", 127, -128}, ""},
+		},
+		{
+			[]byte{3, 0, 0},
+			outType{record{}, "field W: offset cannot decode: 3"},
+		},
+		{
+			[]byte{0, 0x80, 0},
+			outType{record{"a", 0, 0}, "field N: value overflows int8: 128"},
+		},
+		{
+			[]byte{},
+			outType{record{}, io.EOF.Error()},
+		},
+		{
+			[]byte{0, 1},
+			outType{record{}, io.ErrUnexpectedEOF.Error()},
+		},
+	}
+
+	for idx, test := range tests {
+		var out record
+		err := rc.Decode(bytes.NewReader(test.in), om, &out)
+		if test.out.err != "" {
+			if err == nil || err.Error() != test.out.err {
+				t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+		}
+		if test.out.rec != out {
+			t.Errorf("[%d] expected %+v, but got %+v", idx, test.out.rec, out)
+		}
+	}
+
+	var out record
+	if err := rc.DecodeBytes([]byte{0, 0, 0}, om, out); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+	if err := rc.DecodeBytes([]byte{0, 0}, om, &out); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+}
@@ -0,0 +1,126 @@
+package nwenc
+
+import (
+	"fmt"
+	"io"
+)
+
+// recordReaderBufSize is the max bytes which RecordReader reads by one io.ReaderAt call.
+const recordReaderBufSize = 64 * 1024
+
+// RecordReader reads the fixed-width offsets which Encoder writes by their indices.
+// The i-th offset is at i*byteLen bytes, so each read is one io.ReaderAt call.
+// To read an encoded file with the header which Writer writes, pass
+// io.NewSectionReader(f, HeaderSize, size-HeaderSize) as r.
+// It is safe for concurrent use when r is.
+type RecordReader struct {
+	r   io.ReaderAt
+	dec *Decoder
+	n   int64 // the number of records
+}
+
+// NewRecordReader returns a RecordReader of r, whose size is size bytes.
+// The size must be a multiple of the byteLen, which must be 1 <= byteLen <= 8.
+func NewRecordReader(r io.ReaderAt, size int64, byteLen int) (*RecordReader, error) {
+	dec, err := NewDecoder(byteLen)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 || size%int64(byteLen) != 0 {
+		return nil, fmt.Errorf("size is not a multiple of byte length %d: %d", byteLen, size)
+	}
+	return &RecordReader{r: r, dec: dec, n: size / int64(byteLen)}, nil
+}
+
+// Len returns the number of records.
+func (rr *RecordReader) Len() int64 {
+	return rr.n
+}
+
+// ReadOffset reads the i-th offset.
+func (rr *RecordReader) ReadOffset(i int64) (offset int64, err error) {
+	if i < 0 || i >= rr.n {
+		err = fmt.Errorf("record index out of range [0, %d): %d", rr.n, i)
+		return
+	}
+
+	var buf [8]byte
+	b := buf[:rr.dec.l]
+	if err = rr.readAt(b, i); err != nil {
+		return
+	}
+	return rr.dec.decodeBytes(b), nil
+}
+
+// ReadString reads the i-th offset and decodes it by od.
+func (rr *RecordReader) ReadString(i int64, od OffsetDecoder) (s string, err error) {
+	offset, err := rr.ReadOffset(i)
+	if err != nil {
+		return
+	}
+	s, err = od.OffsetDecode(offset)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// ReadOffsets reads the offsets from the i-th one into dst and returns the number of read offsets.
+// When fewer than len(dst) offsets are left, it returns them with io.EOF in the same way as
+// io.ReaderAt.
+func (rr *RecordReader) ReadOffsets(dst []int64, i int64) (n int, err error) {
+	if i < 0 || i > rr.n {
+		err = fmt.Errorf("record index out of range [0, %d]: %d", rr.n, i)
+		return
+	}
+	if rest := rr.n - i; int64(len(dst)) > rest {
+		dst = dst[:rest]
+		err = io.EOF
+	}
+
+	l := rr.dec.l
+	bufLen := len(dst) * l
+	if bufLen > recordReaderBufSize {
+		bufLen = recordReaderBufSize - recordReaderBufSize%l
+	}
+	buf := make([]byte, bufLen)
+
+	for n < len(dst) {
+		b := buf
+		if rest := (len(dst) - n) * l; rest < len(b) {
+			b = b[:rest]
+		}
+		if rerr := rr.readAt(b, i+int64(n)); rerr != nil {
+			return n, rerr
+		}
+		m, _ := rr.dec.DecodeInto(dst[n:], b)
+		n += m
+	}
+	return
+}
+
+// ReadStrings reads the offsets from the i-th one and decodes them into dst by od.
+// It returns the number of decoded strings in the same way as ReadOffsets.
+func (rr *RecordReader) ReadStrings(dst []string, i int64, od OffsetDecoder) (n int, err error) {
+	offsets := make([]int64, len(dst))
+	m, err := rr.ReadOffsets(offsets, i)
+	for ; n < m; n++ {
+		s, derr := od.OffsetDecode(offsets[n])
+		if derr != nil {
+			return n, derr
+		}
+		dst[n] = s
+	}
+	return
+}
+
+// readAt reads b from the i-th record.
+func (rr *RecordReader) readAt(b []byte, i int64) error {
+	if n, err := rr.r.ReadAt(b, i*int64(rr.dec.l)); n < len(b) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
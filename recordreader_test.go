@@ -0,0 +1,198 @@
+package nwenc
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewRecordReader(t *testing.T) {
+	tests := []struct {
+		size    int64
+		byteLen int
+		n       int64
+		ok      bool
+	}{
+		{0, 3, 0, true},
+		{9, 3, 3, true},
+		{10, 3, 0, false},
+		{-3, 3, 0, false},
+		{8, 0, 0, false},
+		{9, 9, 0, false},
+	}
+
+	for idx, test := range tests {
+		rr, err := NewRecordReader(bytes.NewReader(nil), test.size, test.byteLen)
+		if (err == nil) != test.ok {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+			continue
+		}
+		if err == nil && rr.Len() != test.n {
+			t.Errorf("[%d] expected %d, but got %d", idx, test.n, rr.Len())
+		}
+	}
+}
+
+func TestRecordReader_ReadOffset(t *testing.T) {
+	b := []byte{0, 0, 2, 16, 255, 5, 0, 0, 6}
+	rr, err := NewRecordReader(bytes.NewReader(b), int64(len(b)), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	om, err := NewAllReadOffsetMapper(strings.NewReader("a\nbcd\nefg\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type outType struct {
+		offset int64
+		s      string
+		err    bool
+	}
+	tests := []struct {
+		in  int64
+		out outType
+	}{
+		{0, outType{2, "bcd", false}},
+		{1, outType{0x10FF05, "", true}},
+		{2, outType{6, "efg", false}},
+		{3, outType{0, "", true}},
+		{-1, outType{0, "", true}},
+	}
+
+	for idx, test := range tests {
+		offset, err := rr.ReadOffset(test.in)
+		if test.in >= 0 && test.in < rr.Len() {
+			if err != nil || offset != test.out.offset {
+				t.Errorf("[%d] expected (%d, %v), but got (%d, %v)", idx, test.out.offset, nil, offset, err)
+			}
+		} else if err == nil {
+			t.Errorf("[%d] expected error, but got nil", idx)
+		}
+
+		s, err := rr.ReadString(test.in, om)
+		if (err != nil) != test.out.err || s != test.out.s {
+			t.Errorf("[%d] expected %q, but got (%q, %v)", idx, test.out.s, s, err)
+		}
+	}
+}
+
+func TestRecordReader_ReadOffsets(t *testing.T) {
+	// the records are across the buffer of RecordReader
+	const n = recordReaderBufSize/3 + 100
+	enc, _ := NewEncoder(3)
+	offsets := make([]int64, n)
+	for i := range offsets {
+		offsets[i] = int64(i * 7)
+	}
+	b, err := enc.EncodeSlice(nil, offsets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rr, err := NewRecordReader(bytes.NewReader(b), int64(len(b)), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type outType struct {
+		n   int
+		err error
+	}
+	tests := []struct {
+		i   int64
+		len int
+		out outType
+	}{
+		{0, n, outType{n, nil}},
+		{0, 10, outType{10, nil}},
+		{5, n, outType{n - 5, io.EOF}},
+		{n - 1, 2, outType{1, io.EOF}},
+		{n, 2, outType{0, io.EOF}},
+		{n, 0, outType{0, nil}},
+	}
+
+	for idx, test := range tests {
+		dst := make([]int64, test.len)
+		m, err := rr.ReadOffsets(dst, test.i)
+		if m != test.out.n || err != test.out.err {
+			t.Errorf("[%d] expected (%d, %v), but got (%d, %v)", idx, test.out.n, test.out.err, m, err)
+			continue
+		}
+		if !reflect.DeepEqual(offsets[test.i:test.i+int64(m)], dst[:m]) {
+			t.Errorf("[%d] offsets differ", idx)
+		}
+	}
+
+	if _, err := rr.ReadOffsets(make([]int64, 1), n+1); err == nil || err == io.EOF {
+		t.Errorf("expected error, but got %v", err)
+	}
+	if _, err := rr.ReadOffsets(make([]int64, 1), -1); err == nil || err == io.EOF {
+		t.Errorf("expected error, but got %v", err)
+	}
+}
+
+func TestRecordReader_ReadStrings(t *testing.T) {
+	b := []byte{6, 0, 2, 0, 3}
+	rr, err := NewRecordReader(bytes.NewReader(b), int64(len(b)), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	om, err := NewAllReadOffsetMapper(strings.NewReader("a\nbcd\nefg\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type outType struct {
+		s   []string
+		err bool
+	}
+	tests := []struct {
+		i   int64
+		len int
+		out outType
+	}{
+		{0, 4, outType{[]string{"efg", "a", "bcd", "a"}, false}},
+		{2, 2, outType{[]string{"bcd", "a"}, false}},
+		{3, 3, outType{[]string{"a"}, true}},
+		{4, 1, outType{[]string{}, true}},
+	}
+
+	for idx, test := range tests {
+		dst := make([]string, test.len)
+		n, err := rr.ReadStrings(dst, test.i, om)
+		if (err != nil) != test.out.err {
+			t.Errorf("[%d] unexpected error: %v", idx, err)
+		}
+		if !reflect.DeepEqual(test.out.s, dst[:n]) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.s, dst[:n])
+		}
+	}
+}
+
+func TestRecordReader_Header(t *testing.T) {
+	vocab := strings.NewReader("a\nbcd\nefg\n")
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(buf, vocab, vocab.Size(), CodecFixed, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, offset := range []int64{6, 2, 0} {
+		if err := w.Encode(offset); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	rr, err := NewRecordReader(io.NewSectionReader(r, HeaderSize, r.Size()-HeaderSize), r.Size()-HeaderSize, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if offset, err := rr.ReadOffset(1); offset != 2 || err != nil {
+		t.Errorf("expected (%d, %v), but got (%d, %v)", 2, nil, offset, err)
+	}
+}
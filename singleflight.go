@@ -0,0 +1,47 @@
+package nwenc
+
+import "sync"
+
+// flightGroup suppresses duplicate calls of the same key which run concurrently.
+// The zero value is ready to use.
+type flightGroup struct {
+	mu sync.Mutex
+	m  map[string]*flightCall
+}
+
+// flightCall is a call which is running or has finished in flightGroup.
+type flightCall struct {
+	wg     sync.WaitGroup
+	dups   int // the number of the calls which wait for it
+	s      string
+	offset int64
+	err    error
+}
+
+// do calls fn and returns the results. When a call of the same key is already running,
+// do waits for it and returns its results instead of calling fn.
+func (g *flightGroup) do(key string, fn func() (string, int64, error)) (s string, offset int64, err error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = map[string]*flightCall{}
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.s, c.offset, c.err
+	}
+	c := new(flightCall)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	c.s, c.offset, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+
+	return c.s, c.offset, c.err
+}
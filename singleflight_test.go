@@ -0,0 +1,66 @@
+package nwenc
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFlightGroup_Do(t *testing.T) {
+	var g flightGroup
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	fn := func() (string, int64, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return "a", 1, nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, offset, err := g.do("key", fn)
+			if s != "a" || offset != 1 || err != nil {
+				t.Errorf("expected (%#v, %d, %v), but got (%#v, %d, %v)", "a", 1, nil, s, offset, err)
+			}
+		}()
+	}
+
+	// wait for the other goroutines to join the first call
+	<-started
+	for dups(&g, "key") < n-1 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, but got %d", calls)
+	}
+
+	// the finished call must not be shared
+	if _, _, err := g.do("key", fn); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, but got %d", calls)
+	}
+}
+
+// dups returns the number of the calls which wait for the running call of key.
+func dups(g *flightGroup, key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.m[key]; ok {
+		return c.dups
+	}
+	return 0
+}
@@ -0,0 +1,266 @@
+package nwenc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SparseOffsetMapper is the implementation of OffsetMapper.
+// It keeps only sampled lines in memory. A lookup searches the samples and then reads
+// a block between two samples by one io.ReaderAt call, so it works nearly as fast as
+// AllReadOffsetMapper with a fraction of the memory.
+// It is safe for concurrent use when r is.
+type SparseOffsetMapper struct {
+	r       io.ReaderAt
+	size    int64
+	samples []sparseSample // sorted by offset and by s
+}
+
+// sparseSample is a line which SparseOffsetMapper keeps in memory.
+type sparseSample struct {
+	s      string
+	offset int64
+}
+
+// NewSparseOffsetMapper returns a SparseOffsetMapper which keeps every interval-th line.
+// The size is the total bytes of r. This function reads all of r once.
+// The interval must be 1 <= interval.
+func NewSparseOffsetMapper(r io.ReaderAt, size int64, interval int) (*SparseOffsetMapper, error) {
+	if interval < 1 {
+		return nil, fmt.Errorf("invalid interval: %d", interval)
+	}
+
+	var n int
+	return newSparseOffsetMapper(r, size, func(offset int64) bool {
+		keep := n%interval == 0
+		n++
+		return keep
+	})
+}
+
+// NewSparseOffsetMapperBytes returns a SparseOffsetMapper which keeps the first line
+// beginning in every blockSize bytes, so that a lookup reads about blockSize bytes.
+// The size is the total bytes of r. This function reads all of r once.
+// The blockSize must be 1 <= blockSize.
+func NewSparseOffsetMapperBytes(r io.ReaderAt, size int64, blockSize int64) (*SparseOffsetMapper, error) {
+	if blockSize < 1 {
+		return nil, fmt.Errorf("invalid block size: %d", blockSize)
+	}
+
+	var next int64
+	return newSparseOffsetMapper(r, size, func(offset int64) bool {
+		if offset < next {
+			return false
+		}
+		next = (offset/blockSize + 1) * blockSize
+		return true
+	})
+}
+
+// newSparseOffsetMapper returns a SparseOffsetMapper which keeps the lines that keep
+// returns true. The keep is called with the offset of each line in file order.
+func newSparseOffsetMapper(r io.ReaderAt, size int64, keep func(offset int64) bool) (*SparseOffsetMapper, error) {
+	om := &SparseOffsetMapper{r: r, size: size}
+
+	br := bufio.NewReader(io.NewSectionReader(r, 0, size))
+	var offset int64
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 && keep(offset) {
+			om.samples = append(om.samples, sparseSample{
+				s:      strings.TrimSuffix(line, "\n"),
+				offset: offset,
+			})
+		}
+		offset += int64(len(line))
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return om, nil
+}
+
+// OffsetEncode is the implementation of OffsetEncoder.
+// It reads one block by io.ReaderAt unless s is a sample.
+// When s is not found or is empty, it will return OffsetEncodeError.
+func (om *SparseOffsetMapper) OffsetEncode(s string) (offset int64, err error) {
+	if s == "" {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+
+	j := sort.Search(len(om.samples), func(j int) bool { return om.samples[j].s > s }) - 1
+	if j < 0 {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+	if om.samples[j].s == s {
+		return om.samples[j].offset, nil
+	}
+
+	// s is between the j-th and the next samples, so it can be only in the j-th block
+	ok := false
+	err = om.scanBlock(j, func(lineOffset int64, line []byte) bool {
+		if string(line) < s {
+			return true
+		}
+		if string(line) == s {
+			offset, ok = lineOffset, true
+		}
+		return false
+	})
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = &OffsetEncodeError{s: s}
+		return
+	}
+	return
+}
+
+// OffsetDecode is the implementation of OffsetDecoder.
+// It reads one block by io.ReaderAt unless offset is a sample.
+// When offset is not the beginning of a line or points an empty line,
+// it will return OffsetDecodeError.
+func (om *SparseOffsetMapper) OffsetDecode(offset int64) (s string, err error) {
+	j := sort.Search(len(om.samples), func(j int) bool { return om.samples[j].offset > offset }) - 1
+	if j < 0 || offset >= om.size {
+		err = &OffsetDecodeError{offset: offset}
+		return
+	}
+
+	var line []byte
+	if om.samples[j].offset == offset {
+		line = []byte(om.samples[j].s)
+	} else {
+		// offset is between the j-th and the next samples, so it can be only in the j-th block
+		err = om.scanBlock(j, func(lineOffset int64, l []byte) bool {
+			if lineOffset == offset {
+				line = l
+			}
+			return lineOffset < offset
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if len(line) == 0 || !utf8.Valid(line) {
+		err = &OffsetDecodeError{offset: offset}
+		return
+	}
+
+	s = string(line)
+	return
+}
+
+// PrefixSearch is the implementation of PrefixSearcher.
+// It reads the blocks which contain the strings starting with prefix.
+// When no string starts with prefix, it will return OffsetEncodeError.
+func (om *SparseOffsetMapper) PrefixSearch(prefix string) (first, last, n int64, err error) {
+	j := sort.Search(len(om.samples), func(j int) bool { return om.samples[j].s >= prefix }) - 1
+	if j < 0 {
+		j = 0
+	}
+
+	err = om.scan(j, func(lineOffset int64, line []byte) bool {
+		// the empty line, which is not a string, can be only the first line of the sorted file
+		if len(line) == 0 || string(line) < prefix {
+			return true
+		}
+		if !bytes.HasPrefix(line, []byte(prefix)) {
+			return false
+		}
+		if n == 0 {
+			first = lineOffset
+		}
+		last = lineOffset
+		n++
+		return true
+	})
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		err = &OffsetEncodeError{s: prefix}
+		return
+	}
+	return
+}
+
+// scan reads the blocks from the j-th sample in file order and calls fn with each line
+// ('\n' is not included) and its offset until fn returns false.
+func (om *SparseOffsetMapper) scan(j int, fn func(offset int64, line []byte) bool) error {
+	var buf []byte
+	for ; j < len(om.samples); j++ {
+		var begin int64
+		var err error
+		buf, begin, err = om.readBlock(j, buf)
+		if err != nil {
+			return err
+		}
+		if !eachLine(buf, begin, fn) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// scanBlock reads only the j-th block and calls fn with each line in the same way as scan.
+func (om *SparseOffsetMapper) scanBlock(j int, fn func(offset int64, line []byte) bool) error {
+	buf, begin, err := om.readBlock(j, nil)
+	if err != nil {
+		return err
+	}
+	eachLine(buf, begin, fn)
+	return nil
+}
+
+// readBlock reads the j-th block, from the j-th sample to the next one, into buf by one
+// io.ReaderAt call. It returns the block and its offset. The buf is reused when it is large enough.
+func (om *SparseOffsetMapper) readBlock(j int, buf []byte) ([]byte, int64, error) {
+	begin, end := om.samples[j].offset, om.size
+	if j+1 < len(om.samples) {
+		end = om.samples[j+1].offset
+	}
+
+	if int64(cap(buf)) < end-begin {
+		buf = make([]byte, end-begin)
+	}
+	buf = buf[:end-begin]
+	if n, err := om.r.ReadAt(buf, begin); n < len(buf) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	return buf, begin, nil
+}
+
+// eachLine calls fn with each line of the block b at offset begin until fn returns false.
+// It returns false when fn returns false.
+func eachLine(b []byte, begin int64, fn func(offset int64, line []byte) bool) bool {
+	for i := 0; i < len(b); {
+		n := bytes.IndexByte(b[i:], '\n')
+		if n < 0 {
+			n = len(b) - i
+		}
+		if !fn(begin+int64(i), b[i:i+n]) {
+			return false
+		}
+		i += n + 1
+	}
+	return true
+}
@@ -0,0 +1,416 @@
+package nwenc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// openTestSparseOffsetMappers returns SparseOffsetMappers of testdata/words.txt with several intervals.
+func openTestSparseOffsetMappers(t *testing.T, r io.ReaderAt, size int64) map[string]*SparseOffsetMapper {
+	t.Helper()
+
+	oms := map[string]*SparseOffsetMapper{}
+	for _, interval := range []int{1, 2, 3, 100} {
+		om, err := NewSparseOffsetMapper(r, size, interval)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		oms[fmt.Sprintf("lines %d", interval)] = om
+	}
+	for _, blockSize := range []int64{1, 16, 1000} {
+		om, err := NewSparseOffsetMapperBytes(r, size, blockSize)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		oms[fmt.Sprintf("bytes %d", blockSize)] = om
+	}
+	return oms
+}
+
+func TestNewSparseOffsetMapper(t *testing.T) {
+	type inType struct {
+		s        string
+		interval int
+	}
+	type outType struct {
+		samples []sparseSample
+		err     error
+	}
+	tests := []struct {
+		in  inType
+		out outType
+	}{
+		{
+			inType{"a\nbcd\nefg\nhijk\n", 1},
+			outType{[]sparseSample{{"a", 0}, {"bcd", 2}, {"efg", 6}, {"hijk", 10}}, nil},
+		},
+		{
+			inType{"a\nbcd\nefg\nhijk\n", 3},
+			outType{[]sparseSample{{"a", 0}, {"hijk", 10}}, nil},
+		},
+		{
+			inType{"a\nbcd\nefg\nhijk", 2},
+			outType{[]sparseSample{{"a", 0}, {"efg", 6}}, nil},
+		},
+		{
+			inType{"", 2},
+			outType{nil, nil},
+		},
+		{
+			inType{"a\n", 0},
+			outType{nil, fmt.Errorf("invalid interval: %d", 0)},
+		},
+	}
+
+	for idx, test := range tests {
+		r := strings.NewReader(test.in.s)
+		om, err := NewSparseOffsetMapper(r, r.Size(), test.in.interval)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(test.out.samples, om.samples) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.samples, om.samples)
+		}
+	}
+}
+
+func TestNewSparseOffsetMapperBytes(t *testing.T) {
+	type inType struct {
+		s         string
+		blockSize int64
+	}
+	type outType struct {
+		samples []sparseSample
+		err     error
+	}
+	tests := []struct {
+		in  inType
+		out outType
+	}{
+		{
+			inType{"a\nbcd\nefg\nhijk\n", 1},
+			outType{[]sparseSample{{"a", 0}, {"bcd", 2}, {"efg", 6}, {"hijk", 10}}, nil},
+		},
+		{
+			inType{"a\nbcd\nefg\nhijk\n", 4},
+			outType{[]sparseSample{{"a", 0}, {"efg", 6}, {"hijk", 10}}, nil},
+		},
+		{
+			inType{"a\nbcd\nefg\nhijk\n", 100},
+			outType{[]sparseSample{{"a", 0}}, nil},
+		},
+		{
+			inType{"a\n", 0},
+			outType{nil, fmt.Errorf("invalid block size: %d", 0)},
+		},
+	}
+
+	for idx, test := range tests {
+		r := strings.NewReader(test.in.s)
+		om, err := NewSparseOffsetMapperBytes(r, r.Size(), test.in.blockSize)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(test.out.samples, om.samples) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.samples, om.samples)
+		}
+	}
+}
+
+func TestSparseOffsetMapper_OffsetEncode(t *testing.T) {
+	type outType struct {
+		offset int64
+		err    error
+	}
+	tests := []struct {
+		in  string
+		out outType
+	}{
+		{
+			"a",
+			outType{0, nil},
+		},
+		{
+			"aaaabbbbccccddddeeeeffffgggghhhhiii",
+			outType{2, nil},
+		},
+		{
+			"abcd",
+			outType{38, nil},
+		},
+		{
+			"bcd",
+			outType{43, nil},
+		},
+		{
+			"defgh",
+			outType{47, nil},
+		},
+		{
+			"deg",
+			outType{53, nil},
+		},
+		{
+			"ijk",
+			outType{57, nil},
+		},
+		{
+			"ijkl",
+			outType{61, nil},
+		},
+		{
+			"",
+			outType{0, &OffsetEncodeError{s: ""}},
+		},
+		{
+			"0",
+			outType{0, &OffsetEncodeError{s: "0"}},
+		},
+		{
+			"aaaaa",
+			outType{0, &OffsetEncodeError{s: "aaaaa"}},
+		},
+		{
+			"ijkk",
+			outType{0, &OffsetEncodeError{s: "ijkk"}},
+		},
+		{
+			"z",
+			outType{0, &OffsetEncodeError{s: "z"}},
+		},
+	}
+
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, om := range openTestSparseOffsetMappers(t, f, info.Size()) {
+		for idx, test := range tests {
+			offset, err := om.OffsetEncode(test.in)
+			if !reflect.DeepEqual(test.out.err, err) {
+				t.Errorf("[%s %d] expected %v, but got %v", name, idx, test.out.err, err)
+				continue
+			}
+			if err != nil {
+				continue
+			}
+			if test.out.offset != offset {
+				t.Errorf("[%s %d] expected %d, but got %d", name, idx, test.out.offset, offset)
+			}
+		}
+	}
+}
+
+func TestSparseOffsetMapper_OffsetDecode(t *testing.T) {
+	type outType struct {
+		s   string
+		err error
+	}
+	tests := []struct {
+		in  int64
+		out outType
+	}{
+		{
+			0, outType{"a", nil},
+		},
+		{
+			2, outType{"aaaabbbbccccddddeeeeffffgggghhhhiii", nil},
+		},
+		{
+			38, outType{"abcd", nil},
+		},
+		{
+			61, outType{"ijkl", nil},
+		},
+		{
+			-1, outType{"", &OffsetDecodeError{offset: -1}},
+		},
+		{
+			1, outType{"", &OffsetDecodeError{offset: 1}},
+		},
+		{
+			37, outType{"", &OffsetDecodeError{offset: 37}},
+		},
+		{
+			39, outType{"", &OffsetDecodeError{offset: 39}},
+		},
+		{
+			65, outType{"", &OffsetDecodeError{offset: 65}},
+		},
+		{
+			66, outType{"", &OffsetDecodeError{offset: 66}},
+		},
+	}
+
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, om := range openTestSparseOffsetMappers(t, f, info.Size()) {
+		for idx, test := range tests {
+			s, err := om.OffsetDecode(test.in)
+			if !reflect.DeepEqual(test.out.err, err) {
+				t.Errorf("[%s %d] expected %v, but got %v", name, idx, test.out.err, err)
+				continue
+			}
+
+			if test.out.s != s {
+				t.Errorf("[%s %d] expected %#v, but got %#v", name, idx, test.out.s, s)
+			}
+		}
+	}
+}
+
+func TestSparseOffsetMapper_PrefixSearch(t *testing.T) {
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, om := range openTestSparseOffsetMappers(t, f, info.Size()) {
+		testPrefixSearch(t, om)
+	}
+}
+
+// countingReaderAt counts the calls of ReadAt.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	calls int64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	atomic.AddInt64(&r.calls, 1)
+	return r.r.ReadAt(p, off)
+}
+
+func TestSparseOffsetMapper_ReadAtCalls(t *testing.T) {
+	// open test data
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := &countingReaderAt{r: f}
+	om, err := NewSparseOffsetMapper(r, info.Size(), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the words, the misses after the last line of a block and the others
+	words := []string{
+		"a", "abcd", "bcd", "defgh", "deg", "ijk", "ijkl",
+		"abce", "aaaa", "ab", "bcde", "deh", "ijkm", "0", "z",
+	}
+	for _, s := range words {
+		r.calls = 0
+		om.OffsetEncode(s)
+		if r.calls > 1 {
+			t.Errorf("%#v: OffsetEncode expected <= 1 ReadAt, but got %d", s, r.calls)
+		}
+	}
+
+	// all the offsets including the middle of the last line of a block
+	for offset := int64(-1); offset <= info.Size(); offset++ {
+		r.calls = 0
+		om.OffsetDecode(offset)
+		if r.calls > 1 {
+			t.Errorf("%d: OffsetDecode expected <= 1 ReadAt, but got %d", offset, r.calls)
+		}
+	}
+}
+
+func BenchmarkSparseOffsetMapper_OffsetEncode(b *testing.B) {
+	queries := []string{
+		"a",
+		"aaaabbbbccccddddeeeeffffgggghhhhiii",
+		"abcd",
+		"bcd",
+		"defgh",
+		"deg",
+		"ijk",
+		"ijkl",
+	}
+
+	// open file
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	om, err := NewSparseOffsetMapper(f, info.Size(), 4)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		om.OffsetEncode(queries[i%len(queries)])
+	}
+}
+
+func BenchmarkSparseOffsetMapper_OffsetDecode(b *testing.B) {
+	queries := []int64{0, 2, 38, 43, 47, 53, 57, 61}
+
+	// open file
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	om, err := NewSparseOffsetMapper(f, info.Size(), 4)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		om.OffsetDecode(queries[i%len(queries)])
+	}
+}
@@ -0,0 +1,109 @@
+package nwenc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// DecodeError is returned when StreamDecoder fails to decode an offset or a string.
+type DecodeError struct {
+	Record int64 // the index of the offset
+	Pos    int64 // the byte position of the offset in the stream
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode error at record %d (byte %d): %v", e.Record, e.Pos, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// StreamDecoder decodes a stream of offsets by a ByteDecoder. It buffers the underlying
+// reader and always reads whole offsets, so short reads of a pipe or a network connection
+// do not break them. It returns io.EOF at the end of the stream between offsets, and
+// DecodeError which wraps io.ErrUnexpectedEOF when the stream ends in the middle of an offset.
+//
+// Once Decode returns an error other than io.EOF, it returns the same error after that.
+type StreamDecoder struct {
+	r   countingReader
+	dec ByteDecoder
+	n   int64 // the number of decoded offsets
+	err error
+}
+
+// NewStreamDecoder returns a StreamDecoder which reads r and decodes offsets by dec.
+func NewStreamDecoder(r io.Reader, dec ByteDecoder) *StreamDecoder {
+	return &StreamDecoder{r: countingReader{r: bufio.NewReader(r)}, dec: dec}
+}
+
+// Decode reads and decodes the next offset.
+func (sd *StreamDecoder) Decode() (offset int64, err error) {
+	if sd.err != nil {
+		return 0, sd.err
+	}
+
+	pos := sd.r.n
+	offset, err = sd.dec.Decode(&sd.r)
+	if err == io.EOF && sd.r.n > pos {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == io.EOF {
+		return 0, err
+	}
+	if err != nil {
+		sd.err = &DecodeError{Record: sd.n, Pos: pos, Err: err}
+		return 0, sd.err
+	}
+
+	sd.n++
+	return offset, nil
+}
+
+// DecodeString reads and decodes the next string. When od fails to decode the offset,
+// it returns DecodeError which wraps OffsetDecodeError, and the next offset can be decoded.
+func (sd *StreamDecoder) DecodeString(od OffsetDecoder) (s string, err error) {
+	pos := sd.r.n
+	offset, err := sd.Decode()
+	if err != nil {
+		return
+	}
+	s, err = od.OffsetDecode(offset)
+	if err != nil {
+		err = &DecodeError{Record: sd.n - 1, Pos: pos, Err: err}
+		return
+	}
+	return
+}
+
+// Records returns the number of decoded offsets.
+func (sd *StreamDecoder) Records() int64 {
+	return sd.n
+}
+
+// Pos returns the number of bytes read from the stream.
+func (sd *StreamDecoder) Pos() int64 {
+	return sd.r.n
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.n += int64(n)
+	return
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return b, err
+}
@@ -0,0 +1,129 @@
+package nwenc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStreamDecoder_Decode(t *testing.T) {
+	fixed, _ := NewDecoder(3)
+	type inType struct {
+		dec ByteDecoder
+		buf []byte
+	}
+	type outType struct {
+		offsets []int64
+		err     error
+	}
+	tests := []struct {
+		in  inType
+		out outType
+	}{
+		{
+			inType{fixed, []byte{}},
+			outType{nil, nil},
+		},
+		{
+			inType{fixed, []byte{16, 255, 5, 3, 241, 16}},
+			outType{[]int64{0x10FF05, 0x03F110}, nil},
+		},
+		{
+			inType{fixed, []byte{16, 255, 5, 3, 241}},
+			outType{[]int64{0x10FF05}, &DecodeError{Record: 1, Pos: 3, Err: io.ErrUnexpectedEOF}},
+		},
+		{
+			inType{NewVarintDecoder(), []byte{0x01, 0xAC, 0x02, 0x7F}},
+			outType{[]int64{1, 300, 127}, nil},
+		},
+		{
+			inType{NewVarintDecoder(), []byte{0x01, 0xAC, 0x02, 0xFF}},
+			outType{[]int64{1, 300}, &DecodeError{Record: 2, Pos: 3, Err: io.ErrUnexpectedEOF}},
+		},
+		{
+			inType{NewVarintDecoder(), []byte{0x01, 0x80, 0x00, 0x7F}},
+			outType{[]int64{1}, &DecodeError{Record: 1, Pos: 1, Err: ErrOverlongVarint}},
+		},
+	}
+
+	// readers return the readers which return the bytes of b in several ways.
+	readers := func(b []byte) []io.Reader {
+		return []io.Reader{
+			bytes.NewReader(b),
+			iotest.OneByteReader(bytes.NewReader(b)),
+			iotest.HalfReader(bytes.NewReader(b)),
+			iotest.DataErrReader(bytes.NewReader(b)),
+		}
+	}
+
+	for idx, test := range tests {
+		for i, r := range readers(test.in.buf) {
+			sd := NewStreamDecoder(r, test.in.dec)
+
+			var offsets []int64
+			var err error
+			for {
+				var offset int64
+				offset, err = sd.Decode()
+				if err != nil {
+					break
+				}
+				offsets = append(offsets, offset)
+			}
+			if err == io.EOF {
+				err = nil
+			}
+
+			if !reflect.DeepEqual(test.out.offsets, offsets) {
+				t.Errorf("[%d %d] expected %v, but got %v", idx, i, test.out.offsets, offsets)
+			}
+			if !reflect.DeepEqual(test.out.err, err) {
+				t.Errorf("[%d %d] expected %v, but got %v", idx, i, test.out.err, err)
+			}
+			// the error is sticky
+			if _, again := sd.Decode(); err != nil && again != err {
+				t.Errorf("[%d %d] expected %v, but got %v", idx, i, err, again)
+			}
+		}
+	}
+}
+
+func TestStreamDecoder_DecodeString(t *testing.T) {
+	om, err := NewAllReadOffsetMapper(strings.NewReader("a\nbcd\nefg\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dec, _ := NewDecoder(2)
+	sd := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader([]byte{0, 2, 0, 3, 0, 6, 0})), dec)
+
+	if s, err := sd.DecodeString(om); s != "bcd" || err != nil {
+		t.Errorf("expected (%v, %v), but got (%v, %v)", "bcd", nil, s, err)
+	}
+
+	// an offset which cannot be decoded by om does not stop the stream
+	_, err = sd.DecodeString(om)
+	expected := &DecodeError{Record: 1, Pos: 2, Err: &OffsetDecodeError{offset: 3}}
+	if !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected %v, but got %v", expected, err)
+	}
+	var oerr *OffsetDecodeError
+	if !errors.As(err, &oerr) {
+		t.Errorf("expected OffsetDecodeError, but got %v", err)
+	}
+
+	if s, err := sd.DecodeString(om); s != "efg" || err != nil {
+		t.Errorf("expected (%v, %v), but got (%v, %v)", "efg", nil, s, err)
+	}
+
+	_, err = sd.DecodeString(om)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected %v, but got %v", io.ErrUnexpectedEOF, err)
+	}
+	if sd.Records() != 3 || sd.Pos() != 7 {
+		t.Errorf("expected (%d, %d), but got (%d, %d)", 3, 7, sd.Records(), sd.Pos())
+	}
+}
@@ -0,0 +1,125 @@
+package nwenc
+
+import (
+	"errors"
+	"io"
+)
+
+// maxVarintLen is the max length of a varint of a non-negative int64.
+const maxVarintLen = 9
+
+var (
+	// ErrOverlongVarint is returned when a varint has redundant trailing zero groups.
+	ErrOverlongVarint = errors.New("varint is overlong")
+	// ErrMalformedVarint is returned when a varint overflows int64.
+	ErrMalformedVarint = errors.New("varint overflows int64")
+)
+
+// VarintEncoder encodes an int64 to variable-length bytes (unsigned LEB128).
+// Small offsets are encoded to fewer bytes: 1 byte up to 127, 2 bytes up to 16383 and so on.
+type VarintEncoder struct{}
+
+// NewVarintEncoder returns a VarintEncoder.
+func NewVarintEncoder() *VarintEncoder {
+	return &VarintEncoder{}
+}
+
+// Encode encodes offset to bytes and writes it to w.
+// When offset is negative, it will return OffsetRangeError.
+func (e *VarintEncoder) Encode(w io.Writer, offset int64) error {
+	if offset < 0 {
+		return &OffsetRangeError{offset: offset, byteLen: maxVarintLen}
+	}
+
+	buf := make([]byte, 0, maxVarintLen)
+	u := uint64(offset)
+	for u >= 0x80 {
+		buf = append(buf, byte(u)|0x80)
+		u >>= 7
+	}
+	buf = append(buf, byte(u))
+
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	return nil
+}
+
+// EncodeString encodes s to bytes and writes it to w.
+func (e *VarintEncoder) EncodeString(w io.Writer, oe OffsetEncoder, s string) error {
+	offset, err := oe.OffsetEncode(s)
+	if err != nil {
+		return err
+	}
+	if err := e.Encode(w, offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+// VarintDecoder decodes variable-length bytes (unsigned LEB128) to an int64 or a string.
+type VarintDecoder struct{}
+
+// NewVarintDecoder returns a VarintDecoder.
+func NewVarintDecoder() *VarintDecoder {
+	return &VarintDecoder{}
+}
+
+// Decode reads r and decodes to the offset. It reads r byte by byte, so r should be
+// buffered or implement io.ByteReader.
+// It returns io.EOF only when no byte is read and io.ErrUnexpectedEOF when a varint is truncated.
+// It returns ErrOverlongVarint or ErrMalformedVarint for an invalid varint.
+func (d *VarintDecoder) Decode(r io.Reader) (offset int64, err error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = &singleByteReader{r: r}
+	}
+
+	var u uint64
+	for i := 0; i < maxVarintLen; i++ {
+		var b byte
+		b, err = br.ReadByte()
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+
+		u |= uint64(b&0x7F) << uint(7*i)
+		if b < 0x80 {
+			if b == 0 && i > 0 {
+				return 0, ErrOverlongVarint
+			}
+			return int64(u), nil
+		}
+	}
+
+	return 0, ErrMalformedVarint
+}
+
+// DecodeString reads r and decodes to s.
+func (d *VarintDecoder) DecodeString(r io.Reader, od OffsetDecoder) (s string, err error) {
+	offset, err := d.Decode(r)
+	if err != nil {
+		return
+	}
+	s, err = od.OffsetDecode(offset)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// singleByteReader is an io.ByteReader which reads r one byte at a time.
+type singleByteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (r *singleByteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.r, r.buf[:]); err != nil {
+		return 0, err
+	}
+	return r.buf[0], nil
+}
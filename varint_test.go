@@ -0,0 +1,212 @@
+package nwenc
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var (
+	_ ByteEncoder = (*Encoder)(nil)
+	_ ByteEncoder = (*VarintEncoder)(nil)
+	_ ByteDecoder = (*Decoder)(nil)
+	_ ByteDecoder = (*VarintDecoder)(nil)
+)
+
+func TestVarintEncoder_Encode(t *testing.T) {
+	type outType struct {
+		buf []byte
+		err error
+	}
+	tests := []struct {
+		in  []int64
+		out outType
+	}{
+		{
+			[]int64{},
+			outType{nil, nil},
+		},
+		{
+			[]int64{0},
+			outType{[]byte{0x00}, nil},
+		},
+		{
+			[]int64{0x7F},
+			outType{[]byte{0x7F}, nil},
+		},
+		{
+			[]int64{0x80},
+			outType{[]byte{0x80, 0x01}, nil},
+		},
+		{
+			[]int64{300, 2},
+			outType{[]byte{0xAC, 0x02, 0x02}, nil},
+		},
+		{
+			[]int64{0x7FFFFFFFFFFFFFFF},
+			outType{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F}, nil},
+		},
+		{
+			[]int64{-1},
+			outType{nil, &OffsetRangeError{offset: -1, byteLen: maxVarintLen}},
+		},
+	}
+
+	for idx, test := range tests {
+		buf := new(bytes.Buffer)
+		enc := NewVarintEncoder()
+
+		var err error
+		for _, offset := range test.in {
+			if err = enc.Encode(buf, offset); err != nil {
+				break
+			}
+		}
+
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.buf, buf.Bytes()) {
+			t.Errorf("[%d] expected %v, but got %v", idx, test.out.buf, buf.Bytes())
+		}
+	}
+}
+
+func TestVarintDecoder_Decode(t *testing.T) {
+	type outType struct {
+		offset []int64
+		err    error
+	}
+	tests := []struct {
+		in  []byte
+		out outType
+	}{
+		{
+			[]byte{},
+			outType{[]int64{}, nil},
+		},
+		{
+			[]byte{0x00},
+			outType{[]int64{0}, nil},
+		},
+		{
+			[]byte{0xAC, 0x02, 0x02},
+			outType{[]int64{300, 2}, nil},
+		},
+		{
+			[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F},
+			outType{[]int64{0x7FFFFFFFFFFFFFFF}, nil},
+		},
+		{
+			[]byte{0x02, 0xAC},
+			outType{[]int64{2}, io.ErrUnexpectedEOF},
+		},
+		{
+			[]byte{0x80, 0x00},
+			outType{[]int64{}, ErrOverlongVarint},
+		},
+		{
+			[]byte{0xAC, 0x82, 0x00},
+			outType{[]int64{}, ErrOverlongVarint},
+		},
+		{
+			[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x80, 0x01},
+			outType{[]int64{}, ErrMalformedVarint},
+		},
+	}
+
+	for idx, test := range tests {
+		// bytes.Buffer implements io.ByteReader but bytes.NewReader in a struct does not.
+		readers := []io.Reader{
+			bytes.NewBuffer(test.in),
+			struct{ io.Reader }{bytes.NewReader(test.in)},
+		}
+
+		for _, r := range readers {
+			dec := NewVarintDecoder()
+			offsets := []int64{}
+			var err error
+			for {
+				var offset int64
+				offset, err = dec.Decode(r)
+				if err != nil {
+					break
+				}
+				offsets = append(offsets, offset)
+			}
+			if err == io.EOF {
+				err = nil
+			}
+
+			if !reflect.DeepEqual(test.out.err, err) {
+				t.Errorf("[%d] expected %v, but got %v", idx, test.out.err, err)
+			}
+			if !reflect.DeepEqual(test.out.offset, offsets) {
+				t.Errorf("[%d] expected %v, but got %v", idx, test.out.offset, offsets)
+			}
+		}
+	}
+}
+
+func TestVarint_RoundTrip(t *testing.T) {
+	enc := NewVarintEncoder()
+	dec := NewVarintDecoder()
+
+	for shift := uint(0); shift < 63; shift++ {
+		for _, offset := range []int64{1<<shift - 1, 1 << shift, 1<<shift + 1} {
+			buf := new(bytes.Buffer)
+			if err := enc.Encode(buf, offset); err != nil {
+				t.Fatalf("%d: unexpected error: %v", offset, err)
+			}
+			got, err := dec.Decode(buf)
+			if err != nil || got != offset {
+				t.Errorf("expected (%d, %v), but got (%d, %v)", offset, nil, got, err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("%d: %d bytes are left", offset, buf.Len())
+			}
+		}
+	}
+}
+
+func TestVarint_String(t *testing.T) {
+	// prepare OffsetMapper
+	f, err := os.Open(filepath.Join("testdata", "words.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	om, err := NewAllReadOffsetMapper(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	words := []string{"a", "bcd", "ijkl"}
+	buf := new(bytes.Buffer)
+	enc := NewVarintEncoder()
+	for _, s := range words {
+		if err := enc.EncodeString(buf, om, s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if expected := []byte{0, 43, 61}; !reflect.DeepEqual(expected, buf.Bytes()) {
+		t.Errorf("expected %v, but got %v", expected, buf.Bytes())
+	}
+	if err := enc.EncodeString(buf, om, "z"); !reflect.DeepEqual(&OffsetEncodeError{s: "z"}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetEncodeError{s: "z"}, err)
+	}
+
+	dec := NewVarintDecoder()
+	for _, s := range words {
+		got, err := dec.DecodeString(buf, om)
+		if err != nil || got != s {
+			t.Errorf("expected (%#v, %v), but got (%#v, %v)", s, nil, got, err)
+		}
+	}
+	if _, err := dec.DecodeString(bytes.NewBuffer([]byte{100}), om); !reflect.DeepEqual(&OffsetDecodeError{offset: 100}, err) {
+		t.Errorf("expected %v, but got %v", &OffsetDecodeError{offset: 100}, err)
+	}
+}